@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/app"
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/logger"
+	"github.com/janosmiko/gitea-ldap-sync/internal/secrets"
+)
+
+// runCommand runs the subcommand given in the arguments and returns its exit code. It returns false if there is no
+// subcommand, so the sync should be started.
+func runCommand(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+
+	switch args[0] {
+	case "validate-config":
+		path := ""
+		if len(args) > 1 {
+			path = args[1]
+		}
+
+		return validateConfig(path), true
+	case "schema":
+		return printSchema(), true
+	case "import":
+		return importObjects(len(args) > 1 && args[1] == "--dry-run"), true
+	}
+
+	return 0, false
+}
+
+// validateConfig loads the config file (and the environment variables) and prints the effective configuration with
+// the source of each value. Secrets are redacted.
+func validateConfig(path string) int {
+	logger.Configure()
+
+	_, err := config.Load(path)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint:mnd
+
+	for _, s := range config.Effective() {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t(%s)\n", s.Key, s.Value, s.Source)
+	}
+
+	_ = w.Flush()
+
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "\n%s\n", err)
+
+		return 1
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout, "\nConfiguration is valid.")
+
+	return 0
+}
+
+func printSchema() int {
+	b, err := config.Schema()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	_, _ = os.Stdout.Write(b)
+
+	return 0
+}
+
+// importObjects adopts the existing Gitea organizations and teams found in the LDAP directory of every profile.
+func importObjects(dryRun bool) int {
+	logger.Configure()
+
+	log := log.Logger.With().Str("tag", "[import]").Logger()
+
+	cfg, err := config.New()
+	if err != nil {
+		log.Error().Err(err).Msg("Error")
+
+		return 1
+	}
+
+	ctx := signalContext()
+
+	for _, p := range cfg.GetProfiles() {
+		log.Info().Msgf("Importing (profile: %s)", p.Name)
+
+		if err := importProfile(ctx, cfg.ForProfile(p), dryRun); err != nil {
+			log.Error().Err(err).Msgf("Import failed (profile: %s)", p.Name)
+
+			return 1
+		}
+	}
+
+	log.Info().Msg("Import done")
+
+	return 0
+}
+
+func importProfile(ctx context.Context, cfg *config.Config, dryRun bool) error {
+	runConf, err := secrets.Resolve(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	c, err := app.New(ctx, runConf, nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.Import(ctx, dryRun)
+}
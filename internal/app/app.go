@@ -1,7 +1,10 @@
 package app
 
 import (
-	"regexp"
+	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	giteapkg "code.gitea.io/sdk/gitea"
 	"github.com/pkg/errors"
@@ -10,32 +13,31 @@ import (
 	"github.com/janosmiko/gitea-ldap-sync/internal/gitea"
 	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
 	"github.com/janosmiko/gitea-ldap-sync/internal/logger"
-	"github.com/janosmiko/gitea-ldap-sync/internal/stringslice"
 )
 
 type Client struct {
-	Config *config.Config
-	LDAP   *ldap.Client
-	Gitea  *gitea.Client
-	log    logger.Logger
+	Config    *config.Config
+	LDAP      *ldap.Client
+	Gitea     GiteaClient
+	log       logger.Logger
+	ownership *Ownership
+	report    *Report
+	patterns  *patterns
 }
 
-func New(cfg *config.Config) (*Client, error) {
-	ldapClient, err := ldap.New(cfg)
-	if err != nil {
-		return nil, err
-	}
-
-	giteaClient, err := gitea.New(cfg)
+// New creates the sync client of a profile (see config.ForProfile). The Gitea clients of the targets are created by
+// Run. The ownership is shared between the clients of the profiles, it may be nil if there is a single profile.
+func New(ctx context.Context, cfg *config.Config, ownership *Ownership) (*Client, error) {
+	ldapClient, err := ldap.New(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Client{
-		Config: cfg,
-		LDAP:   ldapClient,
-		Gitea:  giteaClient,
-		log:    logger.New().Tag("app"),
+		Config:    cfg,
+		LDAP:      ldapClient,
+		log:       logger.New().Tag("app"),
+		ownership: ownership,
 	}, nil
 }
 
@@ -43,58 +45,165 @@ func (c *Client) Close() {
 	c.LDAP.Close()
 }
 
-func (c *Client) Run() error {
-	ldapDirectory, err := c.LDAP.GetDirectory()
+// Run executes a single sync and returns the report of every Gitea target. The LDAP directory is read once and
+// reconciled with every target. A failing target does not stop the sync of the other targets, their errors are
+// returned together. The run is aborted when ctx is done or when it exceeds the configured run timeout.
+func (c *Client) Run(ctx context.Context) ([]*Report, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Config.RunTimeout)
+	defer cancel()
+
+	ldapDirectory, err := c.LDAP.GetDirectory(ctx)
 	if err != nil {
+		return nil, err
+	}
+
+	for _, col := range ldapDirectory.Collisions {
+		c.log.Warn().Msgf(
+			"LDAP entry skipped (reason: name-collision): %s (%s: %s, used by: %s)", col.DN, col.Kind, col.Name, col.Other,
+		)
+	}
+
+	if c.ownership != nil {
+		c.ownership.Claim(c.profile(), ldapDirectory)
+	}
+
+	targets := c.Config.GetTargets()
+	reports := make([]*Report, 0, len(targets))
+
+	var failed []string
+
+	for _, t := range targets {
+		report, err := c.runTarget(ctx, t, ldapDirectory)
+		reports = append(reports, report)
+
+		if err != nil {
+			c.log.Error().Err(err).Msgf("Sync failed (target: %s)", t.Name)
+
+			failed = append(failed, fmt.Sprintf("target: %s: %s", t.Name, err))
+		}
+	}
+
+	if len(failed) != 0 {
+		return reports, errors.Errorf("sync failed: %s", strings.Join(failed, "; "))
+	}
+
+	return reports, nil
+}
+
+// runTarget reconciles the Gitea target with the LDAP directory.
+func (c *Client) runTarget(ctx context.Context, t *config.Target, ldapDirectory *ldap.Directory) (*Report, error) {
+	tc := &Client{
+		Config:    c.Config.ForTarget(t),
+		LDAP:      c.LDAP,
+		log:       c.log,
+		ownership: c.ownership,
+		report: &Report{
+			Profile: c.profile(), Target: t.Name, Started: time.Now(), NameCollisions: len(ldapDirectory.Collisions),
+		},
+	}
+
+	err := tc.sync(ctx, ldapDirectory)
+
+	tc.report.Duration = time.Since(tc.report.Started)
+	if err != nil {
+		tc.report.Error = err.Error()
+	}
+
+	c.log.Info().Msgf("Sync report: %s", tc.report.Summary())
+
+	return tc.report, err
+}
+
+func (c *Client) sync(ctx context.Context, ldapDirectory *ldap.Directory) error {
+	var err error
+
+	if c.patterns, err = compilePatterns(c.Config); err != nil {
+		return err
+	}
+
+	if c.Gitea, err = gitea.New(ctx, c.Config); err != nil {
 		return err
 	}
 
 	if c.Config.SyncConfig.CreateGroups {
-		if err = c.syncLDAPUsersToGitea(ldapDirectory); err != nil {
+		if err = c.syncLDAPUsersToGitea(ctx, ldapDirectory); err != nil {
 			return err
 		}
 
-		if err = c.syncLDAPGroupsToGitea(ldapDirectory); err != nil {
+		if err = c.syncLDAPGroupsToGitea(ctx, ldapDirectory); err != nil {
 			return err
 		}
 	}
 
-	if err = c.removeGiteaUsersNotInLDAP(ldapDirectory); err != nil {
+	if err = c.removeGiteaUsersNotInLDAP(ctx, ldapDirectory); err != nil {
+		return err
+	}
+
+	if err = c.syncGiteaGroupHierarchyWithLDAP(ctx, ldapDirectory); err != nil {
 		return err
 	}
 
-	if err = c.syncGiteaGroupHierarchyWithLDAP(ldapDirectory); err != nil {
+	if err = c.syncCollaborators(ctx, ldapDirectory); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (c *Client) syncLDAPUsersToGitea(ldapDirectory *ldap.Directory) error {
+func (c *Client) profile() string {
+	if c.Config.ProfileName == "" {
+		return config.DefaultProfile
+	}
+
+	return c.Config.ProfileName
+}
+
+// managedByOther reports whether the object must not be deleted, because it is managed by another profile.
+func (c *Client) managedByOther(key, kind, name string) bool {
+	reason := c.ownership.otherOwner(c.profile(), key)
+	if reason == "" {
+		return false
+	}
+
+	c.report.Skipped++
+	c.log.Info().Msgf("%s is not deleted (reason: %s): %s", kind, reason, name)
+
+	return true
+}
+
+func (c *Client) syncLDAPUsersToGitea(ctx context.Context, ldapDirectory *ldap.Directory) error {
 	c.log.Tag("sync-users-to-gitea")
 	c.log.Info().Msg("Syncing users from ldap to gitea")
 
+	if err := c.renameUsers(ctx, ldapDirectory); err != nil {
+		return err
+	}
+
 	for _, u := range ldapDirectory.Users {
 		c.log.Info().Msgf("Processing ldap user: %s", u.Name)
 
-		if len(c.Config.LDAP.ExcludeUsersRegex) > 0 {
-			r := regexp.MustCompile(c.Config.LDAP.ExcludeUsersRegex)
-			if r.MatchString(u.Name) {
-				c.log.Info().Msgf("User skipped (reason: regex-exclude-list): %s", u.Name)
+		// The exclude lists match the name in LDAP and the Gitea name too.
+		ldapName := u.GetAttributeValue(c.Config.LDAP.UserUsernameAttribute)
 
-				continue
-			}
+		if matchesAny(nil, c.patterns.excludeUsers, ldapName, u.Name) {
+			c.report.Skipped++
+			c.log.Info().Msgf("User skipped (reason: regex-exclude-list): %s", u.Name)
+
+			continue
 		}
 
-		if stringslice.Contains(c.Config.LDAP.ExcludeUsers, u.Name) {
+		if matchesAny(c.Config.LDAP.ExcludeUsers, nil, ldapName, u.Name) {
+			c.report.Skipped++
 			c.log.Info().Msgf("User skipped (reason: exclude-list): %s", u.Name)
 
 			continue
 		}
 
 		if err := c.Gitea.CreateOrUpdateUser(
+			ctx,
 			gitea.User{
-				UserName:   u.GetAttributeValue(c.Config.LDAP.UserUsernameAttribute),
+				UserName:   u.Name,
+				LoginName:  u.GetAttributeValue(c.Config.LDAP.UserUsernameAttribute),
 				FullName:   u.Fullname(c.Config.LDAP),
 				Email:      u.GetAttributeValue(c.Config.LDAP.UserEmailAttribute),
 				AvatarURL:  u.GetAttributeValue(c.Config.LDAP.UserAvatarAttribute),
@@ -103,8 +212,17 @@ func (c *Client) syncLDAPUsersToGitea(ldapDirectory *ldap.Directory) error {
 				Visibility: giteapkg.VisibleTypePrivate,
 			},
 		); err != nil {
+			if errors.Is(err, gitea.ErrOtherAuthSource) {
+				c.report.Skipped++
+				c.log.Warn().Msgf("User skipped (reason: other-auth-source): %s", err)
+
+				continue
+			}
+
 			return err
 		}
+
+		c.report.UsersSynced++
 	}
 
 	c.log.Info().Msg("Syncing users from ldap to gitea finished")
@@ -114,12 +232,12 @@ func (c *Client) syncLDAPUsersToGitea(ldapDirectory *ldap.Directory) error {
 
 // syncLDAPGroupsToGitea iterates through the ldapDirectory.
 // Creates Gitea Organizations based on the LDAP Groups and creates Gitea Teams based on the LDAP Subgroups.
-func (c *Client) syncLDAPGroupsToGitea(ldapDirectory *ldap.Directory) error {
+func (c *Client) syncLDAPGroupsToGitea(ctx context.Context, ldapDirectory *ldap.Directory) error {
 	c.log.Tag("sync-groups-to-gitea")
 	c.log.Info().Msg("Syncing groups and subgroups from ldap")
 
 	for _, o := range ldapDirectory.Organizations {
-		if err := c.syncOrg(o); err != nil {
+		if err := c.syncOrg(ctx, o); err != nil {
 			return err
 		}
 	}
@@ -129,19 +247,19 @@ func (c *Client) syncLDAPGroupsToGitea(ldapDirectory *ldap.Directory) error {
 	return nil
 }
 
-func (c *Client) syncOrg(o *ldap.Organization) error {
+func (c *Client) syncOrg(ctx context.Context, o *ldap.Organization) error {
 	c.log.Debug().Msgf("Processing group: %s", o.Name)
 
-	if c.Config.LDAP.ExcludeGroupsRegex != "" {
-		r := regexp.MustCompile(c.Config.LDAP.ExcludeGroupsRegex)
-		if r.MatchString(o.Name) {
-			c.log.Info().Msgf("Group skipped (reason: regex-exclude-list): %s", o.Name)
+	// The exclude lists match the name in LDAP and the Gitea name too.
+	if matchesAny(nil, c.patterns.excludeGroups, o.LDAPName, o.Name) {
+		c.report.Skipped++
+		c.log.Info().Msgf("Group skipped (reason: regex-exclude-list): %s", o.Name)
 
-			return nil
-		}
+		return nil
 	}
 
-	if stringslice.Contains(c.Config.LDAP.ExcludeGroups, o.Name) {
+	if matchesAny(c.Config.LDAP.ExcludeGroups, nil, o.LDAPName, o.Name) {
+		c.report.Skipped++
 		c.log.Info().Msgf("Group skipped (reason: exclude-list): %s", o.Name)
 
 		return nil
@@ -150,17 +268,20 @@ func (c *Client) syncOrg(o *ldap.Organization) error {
 	c.log.Debug().Msgf("Syncing ldap group to gitea as an organization: %s", o.Name)
 
 	if err := c.Gitea.CreateOrganization(
+		ctx,
 		gitea.Organization{
 			UserName:    o.Name,
-			FullName:    o.GetAttributeValue(c.Config.LDAP.GroupFullNameAttribute),
-			Description: o.GetAttributeValue(c.Config.LDAP.GroupDescriptionAttribute),
+			FullName:    o.FullName,
+			Description: o.Description,
 			Visibility:  c.Config.SyncConfig.Defaults.Organization.Visibility,
 		},
 	); err != nil {
 		return err
 	}
 
-	if err := c.syncTeams(o); err != nil {
+	c.report.OrganizationsSynced++
+
+	if err := c.syncTeams(ctx, o); err != nil {
 		return err
 	}
 
@@ -169,9 +290,9 @@ func (c *Client) syncOrg(o *ldap.Organization) error {
 	return nil
 }
 
-func (c *Client) syncTeams(o *ldap.Organization) error {
+func (c *Client) syncTeams(ctx context.Context, o *ldap.Organization) error {
 	for _, t := range o.Teams {
-		if err := c.syncTeam(o, t); err != nil {
+		if err := c.syncTeam(ctx, o, t); err != nil {
 			return err
 		}
 	}
@@ -179,29 +300,29 @@ func (c *Client) syncTeams(o *ldap.Organization) error {
 	return nil
 }
 
-func (c *Client) syncTeam(o *ldap.Organization, t *ldap.Team) error {
+func (c *Client) syncTeam(ctx context.Context, o *ldap.Organization, t *ldap.Team) error {
 	c.log.Debug().Msgf("Processing subgroup %s", t.Name)
 
-	if len(c.Config.LDAP.ExcludeSubgroupsRegex) > 0 {
-		r := regexp.MustCompile(c.Config.LDAP.ExcludeSubgroupsRegex)
-		if r.MatchString(t.Name) {
-			c.log.Info().Msgf("Subroup skipped (reason: regex-exclude-list): %s", t.Name)
+	if matchesAny(nil, c.patterns.excludeSubgroups, t.LDAPName, t.Name) {
+		c.report.Skipped++
+		c.log.Info().Msgf("Subgroup skipped (reason: regex-exclude-list): %s", t.Name)
 
-			return nil
-		}
+		return nil
 	}
 
-	if stringslice.Contains(c.Config.LDAP.ExcludeSubgroups, o.Name) {
+	if matchesAny(c.Config.LDAP.ExcludeSubgroups, nil, t.LDAPName, t.Name) {
+		c.report.Skipped++
 		c.log.Info().Msgf("Subgroup skipped (reason: exclude-list): %s", t.Name)
 
 		return nil
 	}
 
 	if err := c.Gitea.CreateTeam(
+		ctx,
 		o.Name,
 		gitea.Team{
 			Name:        t.Name,
-			Description: t.GetAttributeValue(c.Config.LDAP.SubgroupDescriptionAttribute),
+			Description: t.Description,
 		},
 		gitea.CreateTeamOpts{
 			Permission:              c.Config.SyncConfig.Defaults.Team.Permission,
@@ -214,16 +335,29 @@ func (c *Client) syncTeam(o *ldap.Organization, t *ldap.Team) error {
 		return err
 	}
 
+	c.report.TeamsSynced++
+
 	c.log.Info().Msgf("Subgroup processed: %s", t.Name)
 
 	return nil
 }
 
-func (c *Client) removeGiteaUsersNotInLDAP(ldapDirectory *ldap.Directory) error {
+func (c *Client) removeGiteaUsersNotInLDAP(ctx context.Context, ldapDirectory *ldap.Directory) error {
 	c.log.Tag("remove-users-from-gitea")
 	c.log.Info().Msg("Syncing Users in Gitea")
 
-	giteaUsers, err := c.Gitea.ListUsers()
+	// The local users and the users of the other authentication sources can't be told apart from the users of the
+	// authentication source, so none of them is deleted.
+	if !c.Gitea.Capabilities().UserSource() {
+		c.log.Warn().Msgf(
+			"Users are not deleted (reason: the authentication source of the users is not reported by the server: %s)",
+			c.Gitea.Capabilities(),
+		)
+
+		return nil
+	}
+
+	giteaUsers, err := c.Gitea.ListUsers(ctx)
 	if err != nil {
 		return err
 	}
@@ -232,13 +366,21 @@ func (c *Client) removeGiteaUsersNotInLDAP(ldapDirectory *ldap.Directory) error
 	c.log.Info().Msgf("%d Users were found in Gitea.", len(giteaUsers))
 
 	for _, giteaUser := range giteaUsers {
-		if giteaUser.UserName == "root" {
-			c.log.Info().Msgf("User skipped (reason: root): %s", giteaUser.UserName)
+		if reason := c.protectedUser(giteaUser.UserName, giteaUser.SourceID); reason != "" {
+			c.log.Info().Msgf("User skipped (reason: %s): %s", reason, giteaUser.UserName)
+
+			continue
+		}
+
+		if c.Gitea.OtherAuthSource(giteaUser.SourceID) {
+			c.log.Debug().Msgf(
+				"User skipped (reason: other-auth-source): %s (source-id: %d)", giteaUser.UserName, giteaUser.SourceID,
+			)
 
 			continue
 		}
 
-		if err := c.removeUserIfNotExistsInLDAP(ldapDirectory, giteaUser); err != nil {
+		if err := c.removeUserIfNotExistsInLDAP(ctx, ldapDirectory, giteaUser); err != nil {
 			return err
 		}
 	}
@@ -246,20 +388,21 @@ func (c *Client) removeGiteaUsersNotInLDAP(ldapDirectory *ldap.Directory) error
 	return nil
 }
 
-func (c *Client) removeUserIfNotExistsInLDAP(ldapDirectory *ldap.Directory, giteaUser *gitea.User) error {
+func (c *Client) removeUserIfNotExistsInLDAP(
+	ctx context.Context, ldapDirectory *ldap.Directory, giteaUser *gitea.User,
+) error {
 	c.log.Info().Msgf("Processing gitea user: %s", giteaUser.UserName)
 
-	if len(c.Config.LDAP.ExcludeUsersRegex) > 0 {
-		r := regexp.MustCompile(c.Config.LDAP.ExcludeUsersRegex)
-		if r.MatchString(giteaUser.UserName) {
-			c.log.Info().Msgf("User skipped (reason: regex-exclude-list): %s", giteaUser.UserName)
+	// The exclude lists match the name in LDAP (the login name of the user) and the Gitea name too.
+	if matchesAny(nil, c.patterns.excludeUsers, giteaUser.LoginName, giteaUser.UserName) {
+		c.log.Info().Msgf("User skipped (reason: regex-exclude-list): %s", giteaUser.UserName)
 
-			return nil
-		}
+		return nil
 	}
 
-	if stringslice.Contains(c.Config.LDAP.ExcludeUsers, giteaUser.UserName) {
+	if matchesAny(c.Config.LDAP.ExcludeUsers, nil, giteaUser.LoginName, giteaUser.UserName) {
 		c.log.Info().Msgf("User skipped (reason: exclude-list): %s", giteaUser.UserName)
+
 		return nil
 	}
 
@@ -271,12 +414,18 @@ func (c *Client) removeUserIfNotExistsInLDAP(ldapDirectory *ldap.Directory, gite
 			return nil
 		}
 
+		if c.managedByOther(userKey(giteaUser.UserName), "User", giteaUser.UserName) {
+			return nil
+		}
+
 		c.log.Info().Msgf("User does not exist in LDAP, deleting from gitea: %s", giteaUser.UserName)
 
-		if err := c.Gitea.DeleteUser(giteaUser.UserName); err != nil {
+		if err := c.Gitea.DeleteUser(ctx, giteaUser.UserName); err != nil {
 			return err
 		}
 
+		c.report.UsersDeleted++
+
 		return nil
 	}
 
@@ -288,7 +437,7 @@ func (c *Client) removeUserIfNotExistsInLDAP(ldapDirectory *ldap.Directory, gite
 // syncGiteaGroupHierarchyWithLDAP iterates through all Gitea Organizations and all Teams inside the organizations.
 // It is going to attach the Gitea Users to Gitea Teams (if the LDAP users are members of the LDAP Subgroups and if
 // those LDAP Subgroups are members of LDAP Groups).
-func (c *Client) syncGiteaGroupHierarchyWithLDAP(ldapDirectory *ldap.Directory) error {
+func (c *Client) syncGiteaGroupHierarchyWithLDAP(ctx context.Context, ldapDirectory *ldap.Directory) error {
 	c.log.Tag("remove-groups-from-gitea")
 
 	c.log.Info().Msg("Syncing users to teams in gitea")
@@ -296,7 +445,7 @@ func (c *Client) syncGiteaGroupHierarchyWithLDAP(ldapDirectory *ldap.Directory)
 	c.log.Debug().Msgf("Organization groups in ldap: %s", ldapDirectory.Organizations.String())
 
 	// Check organizations and teams in Gitea, add users to them.
-	giteaOrgs, err := c.Gitea.ListOrganizations()
+	giteaOrgs, err := c.Gitea.ListOrganizations(ctx)
 	if err != nil {
 		return err
 	}
@@ -305,7 +454,7 @@ func (c *Client) syncGiteaGroupHierarchyWithLDAP(ldapDirectory *ldap.Directory)
 	c.log.Debug().Msgf("Organizations in gitea: %s", giteaOrgs)
 
 	for _, giteaOrg := range giteaOrgs {
-		if err := c.syncGiteaOrganizationWithLDAP(ldapDirectory, giteaOrg); err != nil {
+		if err := c.syncGiteaOrganizationWithLDAP(ctx, ldapDirectory, giteaOrg); err != nil {
 			return err
 		}
 	}
@@ -315,10 +464,19 @@ func (c *Client) syncGiteaGroupHierarchyWithLDAP(ldapDirectory *ldap.Directory)
 	return nil
 }
 
-func (c *Client) syncGiteaOrganizationWithLDAP(ldapDirectory *ldap.Directory, giteaOrg *gitea.Organization) error {
+func (c *Client) syncGiteaOrganizationWithLDAP(
+	ctx context.Context, ldapDirectory *ldap.Directory, giteaOrg *gitea.Organization,
+) error {
 	c.log.Info().Msgf("Processing organization: %s (id: %d)", giteaOrg.UserName, giteaOrg.ID)
 
-	giteaTeams, err := c.Gitea.ListTeams(giteaOrg.UserName)
+	if c.protectedOrganization(giteaOrg.UserName) {
+		c.report.Skipped++
+		c.log.Info().Msgf("Organization skipped (reason: protected): %s", giteaOrg.UserName)
+
+		return nil
+	}
+
+	giteaTeams, err := c.Gitea.ListTeams(ctx, giteaOrg.UserName)
 	if err != nil {
 		return err
 	}
@@ -335,17 +493,38 @@ func (c *Client) syncGiteaOrganizationWithLDAP(ldapDirectory *ldap.Directory, gi
 			return nil
 		}
 
+		if !c.Gitea.IsManaged(giteaOrg.Description) {
+			c.report.Skipped++
+			c.log.Info().Msgf("Organization is not deleted (reason: not managed): %s", giteaOrg.UserName)
+
+			return nil
+		}
+
+		if c.managedByOther(orgKey(giteaOrg.UserName), "Organization", giteaOrg.UserName) {
+			return nil
+		}
+
 		c.log.Info().Msgf("Organization does not exist in LDAP, deleting from gitea: %s", giteaOrg.UserName)
 
-		if err = c.Gitea.DeleteOrganization(giteaOrg.UserName); err != nil {
+		if err = c.Gitea.DeleteOrganization(ctx, giteaOrg.UserName); err != nil {
 			return err
 		}
 
+		c.report.OrganizationsDeleted++
+
 		return nil
 	}
 
+	var orgRepos []string
+
+	if c.managesRepositories() {
+		if orgRepos, err = c.Gitea.ListOrgRepositories(ctx, giteaOrg.UserName); err != nil {
+			return err
+		}
+	}
+
 	for _, giteaTeam := range giteaTeams {
-		if err := c.syncGiteaTeamWithLDAP(org, giteaTeam); err != nil {
+		if err := c.syncGiteaTeamWithLDAP(ctx, org, giteaTeam, orgRepos); err != nil {
 			return err
 		}
 	}
@@ -353,11 +532,35 @@ func (c *Client) syncGiteaOrganizationWithLDAP(ldapDirectory *ldap.Directory, gi
 	return nil
 }
 
-func (c *Client) syncGiteaTeamWithLDAP(org *ldap.Organization, giteaTeam *giteapkg.Team) error {
+func (c *Client) syncGiteaTeamWithLDAP(
+	ctx context.Context, org *ldap.Organization, giteaTeam *giteapkg.Team, orgRepos []string,
+) error {
 	c.log.Info().Msgf("Processing team: %s", giteaTeam.Name)
 
-	if giteaTeam.Name == "Owners" {
-		c.log.Info().Msgf("Team skipped (reason: owner): %s", giteaTeam.Name)
+	if c.protectedTeam(org.Name, giteaTeam.Name) {
+		c.log.Info().Msgf("Team skipped (reason: protected): %s", giteaTeam.Name)
+
+		return nil
+	}
+
+	if giteaTeam.Name == ldap.OwnersTeam {
+		return c.syncOwners(ctx, org, giteaTeam)
+	}
+
+	if !c.Gitea.IsManaged(giteaTeam.Description) {
+		c.report.Skipped++
+
+		// The members of a team found in LDAP are not synced until the team is adopted.
+		if _, ok := org.Teams[giteaTeam.Name]; ok {
+			c.log.Warn().Msgf(
+				"Team skipped (reason: not managed), run `gitea-ldap-sync import` to adopt it: %s/%s",
+				org.Name, giteaTeam.Name,
+			)
+
+			return nil
+		}
+
+		c.log.Info().Msgf("Team skipped (reason: not managed): %s", giteaTeam.Name)
 
 		return nil
 	}
@@ -370,39 +573,101 @@ func (c *Client) syncGiteaTeamWithLDAP(org *ldap.Organization, giteaTeam *giteap
 			return nil
 		}
 
+		if c.managedByOther(teamKey(org.Name, giteaTeam.Name), "Team", giteaTeam.Name) {
+			return nil
+		}
+
 		c.log.Info().Msgf("Team does not exist in ldap, full sync is enabled, deleting from gitea: %s", giteaTeam.Name)
 
-		if err := c.Gitea.DeleteTeam(giteaTeam.ID); err != nil {
+		if err := c.Gitea.DeleteTeam(ctx, giteaTeam.ID); err != nil {
 			return err
 		}
 
+		c.report.TeamsDeleted++
+
 		return nil
 	}
 
-	giteaUsers, err := c.Gitea.ListTeamUsers(giteaTeam.ID)
+	giteaUsers, err := c.Gitea.ListTeamUsers(ctx, giteaTeam.ID)
 	if err != nil {
 		return err
 	}
 
 	c.log.Debug().Msgf("Gitea team %s (id: %d) has %d users", giteaTeam.Name, giteaTeam.ID, len(giteaUsers))
 
-	if err := c.syncGiteaTeamMembers(ldapTeam, giteaTeam, giteaUsers); err != nil {
+	if err := c.syncGiteaTeamMembers(ctx, ldapTeam, giteaTeam, giteaUsers); err != nil {
 		return err
 	}
 
+	if c.managesRepositories() {
+		if err := c.syncTeamRepositories(ctx, org.Name, ldapTeam, giteaTeam, orgRepos); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// syncOwners syncs the members of the Owners team with the owners group of the organization. The Owners team is never
+// left empty: if none of the owners exist in Gitea, nobody is removed from it.
+func (c *Client) syncOwners(ctx context.Context, org *ldap.Organization, giteaTeam *giteapkg.Team) error {
+	if org.Owners == nil {
+		c.log.Info().Msgf("Team skipped (reason: owners not managed): %s", giteaTeam.Name)
+
+		return nil
+	}
+
+	if len(org.Owners.Users) == 0 {
+		c.report.Skipped++
+		c.log.Warn().Msgf("Team skipped (reason: owners group is empty): %s (organization: %s)", giteaTeam.Name, org.Name)
+
+		return nil
+	}
+
+	giteaUsers, err := c.Gitea.ListTeamUsers(ctx, giteaTeam.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := c.addGiteaUsersToTeams(ctx, org.Owners, giteaTeam, giteaUsers); err != nil {
+		return err
+	}
+
+	if giteaUsers, err = c.Gitea.ListTeamUsers(ctx, giteaTeam.ID); err != nil {
+		return err
+	}
+
+	owners := 0
+
+	for login := range giteaUsers {
+		if _, ok := org.Owners.Users[login]; ok {
+			owners++
+		}
+	}
+
+	if owners == 0 {
+		c.report.Skipped++
+		c.log.Warn().Msgf(
+			"Owners are not removed (reason: none of the owners exist in gitea): %s (organization: %s)",
+			giteaTeam.Name, org.Name,
+		)
+
+		return nil
+	}
+
+	return c.removeGiteaUsersFromTeams(ctx, org.Owners, giteaTeam, giteaUsers)
+}
+
 func (c *Client) syncGiteaTeamMembers(
-	ldapTeam *ldap.Team, giteaTeam *giteapkg.Team, giteaAccounts map[string]gitea.Account,
+	ctx context.Context, ldapTeam *ldap.Team, giteaTeam *giteapkg.Team, giteaAccounts map[string]gitea.Account,
 ) error {
 	c.log.Info().Msgf("Checking team in LDAP: %s.", ldapTeam.Name)
 
-	if err := c.addGiteaUsersToTeams(ldapTeam, giteaTeam, giteaAccounts); err != nil {
+	if err := c.addGiteaUsersToTeams(ctx, ldapTeam, giteaTeam, giteaAccounts); err != nil {
 		return err
 	}
 
-	if err := c.removeGiteaUsersFromTeams(ldapTeam, giteaTeam, giteaAccounts); err != nil {
+	if err := c.removeGiteaUsersFromTeams(ctx, ldapTeam, giteaTeam, giteaAccounts); err != nil {
 		return err
 	}
 
@@ -410,13 +675,19 @@ func (c *Client) syncGiteaTeamMembers(
 }
 
 func (c *Client) removeGiteaUsersFromTeams(
-	ldapTeam *ldap.Team, giteaTeam *giteapkg.Team, giteaAccounts map[string]gitea.Account,
+	ctx context.Context, ldapTeam *ldap.Team, giteaTeam *giteapkg.Team, giteaAccounts map[string]gitea.Account,
 ) error {
 	var removeUserCandidates gitea.Accounts
 
 	for _, u := range giteaAccounts {
 		c.log.Debug().Msgf("Processing gitea user: %s", u.String())
 
+		if reason := c.protectedUser(u.Login, u.SourceID); reason != "" {
+			c.log.Debug().Msgf("User is not removed from team (reason: %s): %s", reason, u.Login)
+
+			continue
+		}
+
 		exists, err := existInSlice(u.Login, ldapTeam.Users)
 		if err != nil {
 			return err
@@ -435,24 +706,26 @@ func (c *Client) removeGiteaUsersFromTeams(
 
 	c.log.Info().Msgf("Users will be removed from gitea team: %s (team: %s)", removeUserCandidates, ldapTeam.Name)
 
-	if err := c.Gitea.DelUsersFromTeam(removeUserCandidates, giteaTeam.ID); err != nil {
+	if err := c.Gitea.DelUsersFromTeam(ctx, removeUserCandidates, giteaTeam.ID); err != nil {
 		return err
 	}
 
+	c.report.MembersRemoved += len(removeUserCandidates)
+
 	return nil
 }
 
 func (c *Client) addGiteaUsersToTeams(
-	ldapTeam *ldap.Team, giteaTeam *giteapkg.Team, giteaAccounts map[string]gitea.Account,
+	ctx context.Context, ldapTeam *ldap.Team, giteaTeam *giteapkg.Team, giteaAccounts map[string]gitea.Account,
 ) error {
 	var addUserCandidates gitea.Accounts
 
 	for _, u := range ldapTeam.Users {
 		c.log.Debug().Msgf("Processing gitea team user: %s", u.Name)
 
-		if giteaAccounts[u.Name].Login != u.Entry.GetAttributeValue(c.Config.LDAP.UserUsernameAttribute) {
+		if giteaAccounts[u.Name].Login != u.Name {
 			acc := gitea.Account{
-				Login:    u.Entry.GetAttributeValue(c.Config.LDAP.UserUsernameAttribute),
+				Login:    u.Name,
 				FullName: u.Entry.GetAttributeValue(c.Config.LDAP.UserFullNameAttribute),
 			}
 
@@ -468,10 +741,12 @@ func (c *Client) addGiteaUsersToTeams(
 
 	c.log.Info().Msgf("Users will be added to team: %s (team: %s)", addUserCandidates, ldapTeam.Name)
 
-	if err := c.Gitea.AddUsersToTeam(addUserCandidates, giteaTeam.ID); err != nil {
+	if err := c.Gitea.AddUsersToTeam(ctx, addUserCandidates, giteaTeam.ID); err != nil {
 		return err
 	}
 
+	c.report.MembersAdded += len(addUserCandidates)
+
 	return nil
 }
 
@@ -0,0 +1,418 @@
+package app_test
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/app"
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/gitea"
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+)
+
+func TestSyncOwners(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		owners  []string
+		noGroup bool
+		members []string
+		want    []string
+	}{
+		{
+			name:    "Test if the owners are synced with the owners group",
+			owners:  []string{"alice", "carol"},
+			members: []string{"admin", "alice", "bob"},
+			want:    []string{"admin", "alice", "carol"},
+		},
+		{
+			name:    "Test if the owners are kept if the owners group is empty",
+			owners:  []string{},
+			members: []string{"alice", "bob"},
+			want:    []string{"alice", "bob"},
+		},
+		{
+			name:    "Test if the owners are kept if none of the owners exist in gitea",
+			owners:  []string{"dave"},
+			members: []string{"alice", "bob"},
+			want:    []string{"alice", "bob"},
+		},
+		{
+			name:    "Test if the authenticated user is never removed from the owners",
+			owners:  []string{"alice"},
+			members: []string{"admin", "bob"},
+			want:    []string{"admin", "alice"},
+		},
+		{
+			name:    "Test if the owners are not managed without an owners group",
+			noGroup: true,
+			members: []string{"alice", "bob"},
+			want:    []string{"alice", "bob"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf := newConfig()
+			f := newFakeGitea(t, conf)
+
+			for _, login := range []string{"alice", "bob", "carol"} {
+				f.addUser(login, 1)
+			}
+
+			team := f.addTeam("developers", ldap.OwnersTeam, "", tt.members...)
+
+			org := &ldap.Organization{Name: "developers", Teams: map[string]*ldap.Team{}}
+			if !tt.noGroup {
+				org.Owners = &ldap.Team{Name: ldap.OwnersTeam, Users: ldapUsers(tt.owners...)}
+			}
+
+			c := app.NewTestClient(conf, f)
+
+			if err := c.SyncGiteaTeamWithLDAP(context.Background(), org, team, nil); err != nil {
+				t.Fatalf("SyncGiteaTeamWithLDAP() error = %v", err)
+			}
+
+			if got := f.members(team.ID); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("members = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncTeamRepositories(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		team  string
+		rules []*config.TeamRepositories
+		attrs map[string][]string
+		want  []string
+	}{
+		{
+			name:  "Test if the repositories of a team matching a rule are synced",
+			team:  "backend",
+			rules: []*config.TeamRepositories{{Team: "developers/backend", Repositories: []string{"api-*"}}},
+			want:  []string{"api-orders", "api-users"},
+		},
+		{
+			name:  "Test if a team matching no rule keeps its repositories",
+			team:  "frontend",
+			rules: []*config.TeamRepositories{{Team: "developers/backend", Repositories: []string{"api-*"}}},
+			want:  []string{"web"},
+		},
+		{
+			name:  "Test if the repositories of a team with the repositories attribute are synced",
+			team:  "frontend",
+			attrs: map[string][]string{"gitRepositories": {"web", "api-users"}},
+			want:  []string{"api-users", "web"},
+		},
+		{
+			name:  "Test if a team without the repositories attribute keeps its repositories",
+			team:  "frontend",
+			attrs: map[string][]string{},
+			want:  []string{"web"},
+		},
+		{
+			name:  "Test if a rule without repositories revokes the access to every repository",
+			team:  "frontend",
+			rules: []*config.TeamRepositories{{Team: "developers/frontend"}},
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf := newConfig()
+			conf.SyncConfig.TeamRepositories = tt.rules
+
+			if tt.attrs != nil {
+				conf.LDAP.SubgroupRepositoriesAttribute = "gitRepositories"
+			}
+
+			f := newFakeGitea(t, conf)
+			orgRepos := []string{"api-orders", "api-users", "web"}
+			f.repos["developers"] = orgRepos
+
+			team := f.addTeam("developers", tt.team, "")
+			f.teams[team.ID].repos["web"] = true
+
+			entry := goldap.NewEntry("cn="+tt.team+",ou=groups,dc=example,dc=com", tt.attrs)
+			org := &ldap.Organization{
+				Name:  "developers",
+				Teams: map[string]*ldap.Team{tt.team: {Name: tt.team, Entry: entry, Users: ldapUsers()}},
+			}
+
+			c := app.NewTestClient(conf, f)
+
+			if err := c.SyncGiteaTeamWithLDAP(context.Background(), org, team, orgRepos); err != nil {
+				t.Fatalf("SyncGiteaTeamWithLDAP() error = %v", err)
+			}
+
+			if got := f.teamRepos(team.ID); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("team repositories = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncLDAPGroupsToGiteaExclude(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		excludeGroups    []string
+		excludeSubgroups []string
+		subgroupsRegex   string
+		wantOrgs         []string
+		wantTeams        []string
+	}{
+		{
+			name:      "Test if nothing is excluded without exclude lists",
+			wantOrgs:  []string{"Dev-Ops", "developers"},
+			wantTeams: []string{"developers/Back-End", "developers/frontend"},
+		},
+		{
+			name:          "Test if a group is excluded by its name in ldap",
+			excludeGroups: []string{"Dev Ops"},
+			wantOrgs:      []string{"developers"},
+			wantTeams:     []string{"developers/Back-End", "developers/frontend"},
+		},
+		{
+			name:          "Test if a group is excluded by its gitea name",
+			excludeGroups: []string{"Dev-Ops"},
+			wantOrgs:      []string{"developers"},
+			wantTeams:     []string{"developers/Back-End", "developers/frontend"},
+		},
+		{
+			name:           "Test if a subgroup is excluded by its name in ldap using a regular expression",
+			subgroupsRegex: "^Back End$",
+			wantOrgs:       []string{"Dev-Ops", "developers"},
+			wantTeams:      []string{"developers/frontend"},
+		},
+		{
+			name:             "Test if a subgroup is excluded by its name, not by its organization",
+			excludeSubgroups: []string{"frontend"},
+			wantOrgs:         []string{"Dev-Ops", "developers"},
+			wantTeams:        []string{"developers/Back-End"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf := newConfig()
+			conf.LDAP.ExcludeGroups = tt.excludeGroups
+			conf.LDAP.ExcludeSubgroups = tt.excludeSubgroups
+			conf.LDAP.ExcludeSubgroupsRegex = tt.subgroupsRegex
+
+			dir := &ldap.Directory{Organizations: ldap.Organizations{
+				"Dev-Ops": {Name: "Dev-Ops", LDAPName: "Dev Ops", Teams: map[string]*ldap.Team{}},
+				"developers": {Name: "developers", LDAPName: "developers", Teams: map[string]*ldap.Team{
+					"Back-End": {Name: "Back-End", LDAPName: "Back End"},
+					"frontend": {Name: "frontend", LDAPName: "frontend"},
+				}},
+			}}
+
+			f := newFakeGitea(t, conf)
+			c := app.NewTestClient(conf, f)
+
+			if err := c.SyncLDAPGroupsToGitea(context.Background(), dir); err != nil {
+				t.Fatalf("SyncLDAPGroupsToGitea() error = %v", err)
+			}
+
+			var orgs, teams []string
+
+			for _, o := range f.orgs {
+				orgs = append(orgs, o.UserName)
+			}
+
+			for _, team := range f.teams {
+				teams = append(teams, team.org+"/"+team.team.Name)
+			}
+
+			sort.Strings(orgs)
+			sort.Strings(teams)
+
+			if !reflect.DeepEqual(orgs, tt.wantOrgs) {
+				t.Errorf("organizations = %v, want %v", orgs, tt.wantOrgs)
+			}
+
+			if !reflect.DeepEqual(teams, tt.wantTeams) {
+				t.Errorf("teams = %v, want %v", teams, tt.wantTeams)
+			}
+		})
+	}
+}
+
+func TestRemoveGiteaUsersNotInLDAP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		version      string
+		excludeUsers []string
+		excludeRegex string
+		want         []string
+		wantDeleted  int
+	}{
+		{
+			name:        "Test if only the users of the authentication source are deleted",
+			version:     "1.22.3",
+			want:        []string{"admin", "bot", "jdoe", "oauth-user"},
+			wantDeleted: 3,
+		},
+		{
+			name:    "Test if no user is deleted if the server doesn't report the authentication source",
+			version: "1.17.0",
+			want:    []string{"admin", "bot", "ghost", "jdoe", "john-doe", "oauth-user", "svc-backup"},
+		},
+		{
+			name:         "Test if the excluded users are matched by their ldap name",
+			version:      "1.22.3",
+			excludeUsers: []string{"John.Doe"},
+			excludeRegex: `^svc\.`,
+			want:         []string{"admin", "bot", "jdoe", "john-doe", "oauth-user", "svc-backup"},
+			wantDeleted:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf := newConfig()
+			conf.SyncConfig.Protected.LocalUsers = true
+			conf.LDAP.ExcludeUsers = tt.excludeUsers
+			conf.LDAP.ExcludeUsersRegex = tt.excludeRegex
+
+			f := newFakeGitea(t, conf)
+
+			caps, err := gitea.ParseServerVersion(tt.version, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f.caps = caps
+			f.addUser("bot", 0)
+			f.addUser("oauth-user", 2)
+			f.addUser("jdoe", 1)
+			f.addUser("ghost", 1)
+			// Sanitized user names, the login name is the name in LDAP.
+			f.addUser("john-doe", 1).LoginName = "John.Doe"
+			f.addUser("svc-backup", 1).LoginName = "svc.backup"
+
+			c := app.NewTestClient(conf, f)
+
+			if err := c.RemoveGiteaUsersNotInLDAP(context.Background(), &ldap.Directory{Users: ldapUsers("jdoe")}); err != nil {
+				t.Fatalf("RemoveGiteaUsersNotInLDAP() error = %v", err)
+			}
+
+			var got []string
+			for login := range f.users {
+				got = append(got, login)
+			}
+
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("users = %v, want %v", got, tt.want)
+			}
+
+			if c.Report().UsersDeleted != tt.wantDeleted {
+				t.Errorf("UsersDeleted = %d, want %d", c.Report().UsersDeleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+// stalledLDAP starts an LDAP server which accepts every bind, but never answers the searches. It returns its URL.
+func stalledLDAP(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			t.Cleanup(func() { conn.Close() })
+
+			go func() {
+				for {
+					packet, err := ber.ReadPacket(conn)
+					if err != nil || len(packet.Children) < 2 {
+						return
+					}
+
+					if packet.Children[1].Tag != goldap.ApplicationBindRequest {
+						continue
+					}
+
+					res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationBindResponse, nil, "")
+					res.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, 0, ""))
+					res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+					res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+
+					envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+					envelope.AppendChild(packet.Children[0])
+					envelope.AppendChild(res)
+
+					if _, err := conn.Write(envelope.Bytes()); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return "ldap://" + listener.Addr().String()
+}
+
+func TestRunTimeout(t *testing.T) {
+	t.Parallel()
+
+	conf := newConfig()
+	conf.LDAP.URL = stalledLDAP(t)
+	conf.LDAP.BindMethod = config.BindMethodSimple
+	conf.LDAP.ConnectTimeout = 10 * time.Second
+	conf.LDAP.OperationTimeout = time.Minute
+	conf.Retry = &config.RetryConfig{MaxAttempts: 1}
+	conf.RunTimeout = 200 * time.Millisecond
+
+	c, err := app.New(context.Background(), conf, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	defer c.Close()
+
+	started := time.Now()
+
+	if _, err := c.Run(context.Background()); err == nil {
+		t.Fatal("Run() error = nil, want the run to be aborted by the run timeout")
+	}
+
+	// The connect and the operation timeouts of the LDAP client are longer.
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Run() returned after %s, want it to be aborted after the run timeout", elapsed)
+	}
+}
@@ -0,0 +1,229 @@
+package app
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+
+	giteapkg "code.gitea.io/sdk/gitea"
+	"github.com/pkg/errors"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/gitea"
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+	"github.com/janosmiko/gitea-ldap-sync/internal/state"
+)
+
+//nolint:gochecknoglobals
+var permissionRank = map[giteapkg.AccessMode]int{
+	giteapkg.AccessModeRead:  1,
+	giteapkg.AccessModeWrite: 2, //nolint:mnd
+	giteapkg.AccessModeAdmin: 3, //nolint:mnd
+}
+
+// collaboratorsKey is the key of the collaborators added by the sync in the state.
+func (c *Client) collaboratorsKey() string {
+	return "collaborators/" + c.profile() + "/" + c.Config.TargetName
+}
+
+// syncCollaborators reconciles the collaborators of the repositories with the collaborator rules. Only the
+// collaborators added by the sync are removed, they are remembered in the state between the runs.
+func (c *Client) syncCollaborators(ctx context.Context, ldapDirectory *ldap.Directory) error {
+	store, err := state.Open(c.Config.StateFile)
+	if err != nil {
+		return err
+	}
+
+	// The logins of the collaborators added by the sync per repository.
+	added := make(map[string][]string)
+	if _, err := store.Get(c.collaboratorsKey(), &added); err != nil {
+		return err
+	}
+
+	if len(c.Config.SyncConfig.Collaborators) == 0 && len(added) == 0 {
+		return nil
+	}
+
+	c.log.Tag("sync-collaborators")
+	c.log.Info().Msg("Syncing repository collaborators")
+
+	want := c.wantedCollaborators(ldapDirectory)
+
+	giteaUsers, err := c.Gitea.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The LDAP users who don't exist in Gitea can't be added as collaborators.
+	exists := make(map[string]bool, len(giteaUsers))
+	for _, u := range giteaUsers {
+		exists[strings.ToLower(u.UserName)] = true
+	}
+
+	repos := make([]string, 0, len(want)+len(added))
+	for repo := range want {
+		repos = append(repos, repo)
+	}
+
+	for repo := range added {
+		if _, ok := want[repo]; !ok {
+			repos = append(repos, repo)
+		}
+	}
+
+	sort.Strings(repos)
+
+	// A failing repository does not stop the sync of the other repositories, their errors are returned together.
+	var failed []string
+
+	for _, repo := range repos {
+		logins, err := c.syncRepositoryCollaborators(ctx, repo, want[repo], added[repo], exists)
+
+		if len(logins) == 0 {
+			delete(added, repo)
+		} else {
+			added[repo] = logins
+		}
+
+		if err != nil {
+			c.log.Error().Err(err).Msgf("Syncing collaborators failed (repository: %s)", repo)
+
+			failed = append(failed, err.Error())
+		}
+	}
+
+	// The state is saved even if the sync failed, so the collaborators added before the failure are remembered.
+	if err := store.Set(c.collaboratorsKey(), added); err != nil {
+		return err
+	}
+
+	if len(failed) != 0 {
+		return errors.Errorf("syncing collaborators failed: %s", strings.Join(failed, "; "))
+	}
+
+	c.log.Info().Msg("Syncing repository collaborators finished")
+
+	return nil
+}
+
+// wantedCollaborators returns the permission of the users per repository. If a user matches multiple rules of a
+// repository, the highest permission is used.
+func (c *Client) wantedCollaborators(ldapDirectory *ldap.Directory) map[string]map[string]giteapkg.AccessMode {
+	want := make(map[string]map[string]giteapkg.AccessMode)
+
+	for _, rule := range c.Config.SyncConfig.Collaborators {
+		users, ok := ldapDirectory.GroupUsers(rule.Group)
+		if !ok {
+			c.log.Warn().Msgf("Collaborator group does not exist in ldap: %s (repository: %s)", rule.Group, rule.Repository)
+
+			continue
+		}
+
+		repo := strings.ToLower(rule.Repository)
+		if want[repo] == nil {
+			want[repo] = make(map[string]giteapkg.AccessMode)
+		}
+
+		for login := range users {
+			if permissionRank[rule.Permission] > permissionRank[want[repo][login]] {
+				want[repo][login] = rule.Permission
+			}
+		}
+	}
+
+	return want
+}
+
+// syncRepositoryCollaborators adds the wanted users to the collaborators of the repository and removes the ones added
+// by the sync which are not wanted anymore. Collaborators added by others are never modified and the users missing
+// from Gitea are skipped. It returns the logins of the collaborators added by the sync.
+func (c *Client) syncRepositoryCollaborators(
+	ctx context.Context, repo string, want map[string]giteapkg.AccessMode, added []string, exists map[string]bool,
+) ([]string, error) {
+	owner, name, _ := strings.Cut(repo, "/")
+
+	current, err := c.Gitea.ListCollaborators(ctx, owner, name)
+	if err != nil {
+		if errors.Is(err, gitea.ErrNotFound) {
+			c.report.Skipped++
+			c.log.Warn().Msgf("Collaborators skipped (reason: repository does not exist): %s", repo)
+
+			return nil, nil
+		}
+
+		return added, err
+	}
+
+	// On failure the collaborators added before are remembered too.
+	failed := func(result []string) []string {
+		merged := append(slices.Clone(added), result...)
+		slices.Sort(merged)
+
+		return slices.Compact(merged)
+	}
+
+	isCollaborator := make(map[string]bool, len(current))
+	for _, login := range current {
+		isCollaborator[strings.ToLower(login)] = true
+	}
+
+	var result []string
+
+	for _, login := range added {
+		if _, ok := want[login]; ok || !isCollaborator[strings.ToLower(login)] {
+			continue
+		}
+
+		if reason := c.protectedLogin(login); reason != "" {
+			c.log.Info().Msgf("Collaborator is not removed (reason: %s): %s (repository: %s)", reason, login, repo)
+
+			result = append(result, login)
+
+			continue
+		}
+
+		if err := c.Gitea.DeleteCollaborator(ctx, owner, name, login); err != nil {
+			return failed(result), err
+		}
+
+		c.report.CollaboratorsRemoved++
+	}
+
+	logins := make([]string, 0, len(want))
+	for login := range want {
+		logins = append(logins, login)
+	}
+
+	sort.Strings(logins)
+
+	for _, login := range logins {
+		switch {
+		case !exists[strings.ToLower(login)]:
+			c.report.Skipped++
+			c.log.Info().Msgf(
+				"Collaborator skipped (reason: user does not exist in gitea): %s (repository: %s)", login, repo,
+			)
+
+			continue
+		case !isCollaborator[strings.ToLower(login)]:
+			if err := c.Gitea.AddCollaborator(ctx, owner, name, login, want[login]); err != nil {
+				return failed(result), err
+			}
+
+			c.report.CollaboratorsAdded++
+		case slices.Contains(added, login):
+			// Keep the permission of the collaborators added by the sync up to date.
+			if err := c.Gitea.AddCollaborator(ctx, owner, name, login, want[login]); err != nil {
+				return failed(result), err
+			}
+		default:
+			c.log.Debug().Msgf("Collaborator skipped (reason: not added by the sync): %s (repository: %s)", login, repo)
+
+			continue
+		}
+
+		result = append(result, login)
+	}
+
+	return result, nil
+}
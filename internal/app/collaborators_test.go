@@ -0,0 +1,97 @@
+package app_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	giteapkg "code.gitea.io/sdk/gitea"
+	"github.com/pkg/errors"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/app"
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+)
+
+func TestSyncCollaborators(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		fail        map[string]error
+		want        map[string]map[string]giteapkg.AccessMode
+		wantSkipped int
+		wantErr     string
+	}{
+		{
+			name: "Test if the users missing from gitea are skipped",
+			want: map[string]map[string]giteapkg.AccessMode{
+				"jdoe/api": {"alice": giteapkg.AccessModeWrite, "bob": giteapkg.AccessModeWrite},
+				"jdoe/web": {"alice": giteapkg.AccessModeRead, "bob": giteapkg.AccessModeRead},
+			},
+			wantSkipped: 2,
+		},
+		{
+			name: "Test if a failing repository does not stop the sync of the other repositories",
+			fail: map[string]error{"jdoe/api": errors.New("500 Internal Server Error")},
+			want: map[string]map[string]giteapkg.AccessMode{
+				"jdoe/api": {},
+				"jdoe/web": {"alice": giteapkg.AccessModeRead, "bob": giteapkg.AccessModeRead},
+			},
+			wantSkipped: 1,
+			wantErr:     "500 Internal Server Error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf := newConfig()
+			conf.StateFile = filepath.Join(t.TempDir(), "state.json")
+			conf.SyncConfig.Collaborators = []*config.Collaborators{
+				{Group: "developers", Repository: "jdoe/api", Permission: giteapkg.AccessModeWrite},
+				{Group: "developers/backend", Repository: "jdoe/web", Permission: giteapkg.AccessModeRead},
+			}
+
+			f := newFakeGitea(t, conf)
+			f.addUser("alice", 1)
+			f.addUser("bob", 1)
+
+			for repo := range tt.want {
+				f.collaborators[repo] = make(map[string]giteapkg.AccessMode)
+			}
+
+			for repo, err := range tt.fail {
+				f.fail[repo] = err
+			}
+
+			dir := &ldap.Directory{Organizations: ldap.Organizations{
+				"developers": {
+					Name:  "developers",
+					Teams: map[string]*ldap.Team{"backend": {Name: "backend", Users: ldapUsers("alice", "bob", "ghost")}},
+				},
+			}}
+
+			c := app.NewTestClient(conf, f)
+
+			err := c.SyncCollaborators(context.Background(), dir)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("SyncCollaborators() error = %v", err)
+			}
+
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("SyncCollaborators() error = %v, wantErr %q", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(f.collaborators, tt.want) {
+				t.Errorf("collaborators = %v, want %v", f.collaborators, tt.want)
+			}
+
+			if got := c.Report().Skipped; got != tt.wantSkipped {
+				t.Errorf("skipped = %d, want %d", got, tt.wantSkipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+
+	giteapkg "code.gitea.io/sdk/gitea"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+	"github.com/janosmiko/gitea-ldap-sync/internal/logger"
+)
+
+// NewTestClient returns the sync client of a Gitea target for the tests of the app_test package.
+func NewTestClient(cfg *config.Config, giteaClient GiteaClient) *Client {
+	p, err := compilePatterns(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	return &Client{
+		Config:   cfg,
+		Gitea:    giteaClient,
+		log:      logger.New().Tag("app"),
+		report:   &Report{},
+		patterns: p,
+	}
+}
+
+// Report returns the report of the sync.
+func (c *Client) Report() *Report {
+	return c.report
+}
+
+func (c *Client) ProtectedUser(login string, sourceID int64) string {
+	return c.protectedUser(login, sourceID)
+}
+
+func (c *Client) SyncGiteaTeamWithLDAP(
+	ctx context.Context, org *ldap.Organization, giteaTeam *giteapkg.Team, orgRepos []string,
+) error {
+	return c.syncGiteaTeamWithLDAP(ctx, org, giteaTeam, orgRepos)
+}
+
+func (c *Client) RemoveGiteaUsersNotInLDAP(ctx context.Context, ldapDirectory *ldap.Directory) error {
+	return c.removeGiteaUsersNotInLDAP(ctx, ldapDirectory)
+}
+
+func (c *Client) SyncCollaborators(ctx context.Context, ldapDirectory *ldap.Directory) error {
+	return c.syncCollaborators(ctx, ldapDirectory)
+}
+
+func (c *Client) SyncLDAPGroupsToGitea(ctx context.Context, ldapDirectory *ldap.Directory) error {
+	return c.syncLDAPGroupsToGitea(ctx, ldapDirectory)
+}
+
+func (c *Client) RenameUsers(ctx context.Context, ldapDirectory *ldap.Directory) error {
+	return c.renameUsers(ctx, ldapDirectory)
+}
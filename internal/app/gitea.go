@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+
+	giteapkg "code.gitea.io/sdk/gitea"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/gitea"
+)
+
+// GiteaClient is the Gitea API used by the sync. It's implemented by *gitea.Client.
+type GiteaClient interface {
+	Capabilities() *gitea.Capabilities
+	CurrentUser() *gitea.User
+	IsManaged(description string) bool
+	OtherAuthSource(sourceID int64) bool
+
+	ListUsers(ctx context.Context) ([]*gitea.User, error)
+	GetUser(ctx context.Context, username string) (*gitea.User, error)
+	CreateOrUpdateUser(ctx context.Context, u gitea.User) error
+	RenameUser(ctx context.Context, username, newName string) error
+	DeleteUser(ctx context.Context, username string) error
+
+	ListOrganizations(ctx context.Context) (gitea.Organizations, error)
+	CreateOrganization(ctx context.Context, o gitea.Organization) error
+	DeleteOrganization(ctx context.Context, orgname string) error
+	AdoptOrganization(ctx context.Context, o *gitea.Organization) error
+
+	ListTeams(ctx context.Context, orgname string) ([]*giteapkg.Team, error)
+	CreateTeam(ctx context.Context, orgname string, team gitea.Team, opts gitea.CreateTeamOpts) error
+	DeleteTeam(ctx context.Context, teamID int64) error
+	AdoptTeam(ctx context.Context, t *gitea.Team) error
+	ListTeamUsers(ctx context.Context, teamID int64) (map[string]gitea.Account, error)
+	AddUsersToTeam(ctx context.Context, users []gitea.Account, team int64) error
+	DelUsersFromTeam(ctx context.Context, users []gitea.Account, team int64) error
+
+	ListOrgRepositories(ctx context.Context, orgname string) ([]string, error)
+	ListTeamRepositories(ctx context.Context, teamID int64) ([]string, error)
+	AddTeamRepository(ctx context.Context, teamID int64, orgname, repo string) error
+	RemoveTeamRepository(ctx context.Context, teamID int64, orgname, repo string) error
+
+	ListCollaborators(ctx context.Context, owner, repo string) ([]string, error)
+	AddCollaborator(ctx context.Context, owner, repo, login string, permission giteapkg.AccessMode) error
+	DeleteCollaborator(ctx context.Context, owner, repo, login string) error
+}
@@ -0,0 +1,384 @@
+package app_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	giteapkg "code.gitea.io/sdk/gitea"
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/gitea"
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+)
+
+// fakeTeam is a team of the fake Gitea.
+type fakeTeam struct {
+	org     string
+	team    *giteapkg.Team
+	members map[string]bool
+	repos   map[string]bool
+}
+
+// fakeGitea is an in-memory Gitea implementing the API used by the sync. The names are case-insensitive.
+type fakeGitea struct {
+	conf  *config.Config
+	caps  *gitea.Capabilities
+	me    *gitea.User
+	users map[string]*gitea.User
+	orgs  map[string]*gitea.Organization
+	teams map[int64]*fakeTeam
+	// repos are the repositories of the organizations.
+	repos map[string][]string
+	// collaborators are the permissions of the collaborators by <owner>/<repository>.
+	collaborators map[string]map[string]giteapkg.AccessMode
+	// fail is the error returned by the calls changing the collaborators of a repository by <owner>/<repository>.
+	fail   map[string]error
+	nextID int64
+}
+
+func newFakeGitea(t *testing.T, conf *config.Config) *fakeGitea {
+	t.Helper()
+
+	caps, err := gitea.ParseServerVersion("1.22.3", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeGitea{
+		conf:          conf,
+		caps:          caps,
+		users:         make(map[string]*gitea.User),
+		orgs:          make(map[string]*gitea.Organization),
+		teams:         make(map[int64]*fakeTeam),
+		repos:         make(map[string][]string),
+		collaborators: make(map[string]map[string]giteapkg.AccessMode),
+		fail:          make(map[string]error),
+	}
+
+	f.me = f.addUser("admin", 0)
+
+	return f
+}
+
+// newConfig returns the configuration of a sync using the authentication source 1.
+func newConfig() *config.Config {
+	return &config.Config{
+		Gitea: &config.GiteaConfig{AuthSourceID: 1},
+		LDAP:  &config.LDAPConfig{UserFullNameAttribute: "cn"},
+		SyncConfig: &config.SyncConfig{
+			FullSync: true,
+		},
+		ProfileName: "default",
+		TargetName:  "default",
+	}
+}
+
+// ldapUsers returns the LDAP users with the given names.
+func ldapUsers(names ...string) map[string]*ldap.User {
+	users := make(map[string]*ldap.User, len(names))
+
+	for _, name := range names {
+		entry := goldap.NewEntry("cn="+name+",ou=users,dc=example,dc=com", map[string][]string{"cn": {name}})
+		users[name] = &ldap.User{Entry: entry, Name: name}
+	}
+
+	return users
+}
+
+// addUser adds a user of the authentication source.
+func (f *fakeGitea) addUser(login string, sourceID int64) *gitea.User {
+	f.nextID++
+
+	u := &gitea.User{ID: f.nextID, UserName: login, LoginName: login, SourceID: sourceID}
+	f.users[strings.ToLower(login)] = u
+
+	return u
+}
+
+// addTeam adds a team with its members to the organization.
+func (f *fakeGitea) addTeam(org, name, description string, members ...string) *giteapkg.Team {
+	f.nextID++
+
+	team := &giteapkg.Team{ID: f.nextID, Name: name, Description: description}
+	f.teams[team.ID] = &fakeTeam{org: org, team: team, members: make(map[string]bool), repos: make(map[string]bool)}
+
+	for _, m := range members {
+		f.teams[team.ID].members[strings.ToLower(m)] = true
+	}
+
+	return team
+}
+
+// members returns the sorted logins of the members of the team.
+func (f *fakeGitea) members(teamID int64) []string {
+	var logins []string
+
+	for login := range f.teams[teamID].members {
+		logins = append(logins, f.users[login].UserName)
+	}
+
+	sort.Strings(logins)
+
+	return logins
+}
+
+// teamRepos returns the sorted repositories of the team.
+func (f *fakeGitea) teamRepos(teamID int64) []string {
+	var repos []string
+
+	for repo := range f.teams[teamID].repos {
+		repos = append(repos, repo)
+	}
+
+	sort.Strings(repos)
+
+	return repos
+}
+
+func (f *fakeGitea) Capabilities() *gitea.Capabilities {
+	return f.caps
+}
+
+func (f *fakeGitea) CurrentUser() *gitea.User {
+	return f.me
+}
+
+func (f *fakeGitea) IsManaged(description string) bool {
+	marker := f.conf.SyncConfig.OwnershipMarker
+
+	return marker == "" || strings.Contains(description, marker)
+}
+
+func (f *fakeGitea) OtherAuthSource(sourceID int64) bool {
+	return f.caps.UserSource() && sourceID != f.conf.Gitea.AuthSourceID
+}
+
+func (f *fakeGitea) ListUsers(_ context.Context) ([]*gitea.User, error) {
+	users := make([]*gitea.User, 0, len(f.users))
+	for _, u := range f.users {
+		users = append(users, u)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].UserName < users[j].UserName })
+
+	return users, nil
+}
+
+func (f *fakeGitea) GetUser(_ context.Context, username string) (*gitea.User, error) {
+	return f.users[strings.ToLower(username)], nil
+}
+
+func (f *fakeGitea) CreateOrUpdateUser(_ context.Context, u gitea.User) error {
+	existing, ok := f.users[strings.ToLower(u.UserName)]
+	if !ok {
+		f.addUser(u.UserName, f.conf.Gitea.AuthSourceID)
+
+		return nil
+	}
+
+	if f.OtherAuthSource(existing.SourceID) {
+		return errors.Wrapf(gitea.ErrOtherAuthSource, "user: %s", u.UserName)
+	}
+
+	return nil
+}
+
+func (f *fakeGitea) RenameUser(_ context.Context, username, newName string) error {
+	u, ok := f.users[strings.ToLower(username)]
+	if !ok {
+		return errors.Errorf("user does not exist: %s", username)
+	}
+
+	if _, ok := f.users[strings.ToLower(newName)]; ok {
+		return errors.Errorf("user already exists: %s", newName)
+	}
+
+	delete(f.users, strings.ToLower(username))
+
+	u.UserName, u.LoginName = newName, newName
+	f.users[strings.ToLower(newName)] = u
+
+	for _, t := range f.teams {
+		if t.members[strings.ToLower(username)] {
+			delete(t.members, strings.ToLower(username))
+			t.members[strings.ToLower(newName)] = true
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeGitea) DeleteUser(_ context.Context, username string) error {
+	delete(f.users, strings.ToLower(username))
+
+	return nil
+}
+
+func (f *fakeGitea) ListOrganizations(_ context.Context) (gitea.Organizations, error) {
+	orgs := make(gitea.Organizations, 0, len(f.orgs))
+	for _, o := range f.orgs {
+		orgs = append(orgs, o)
+	}
+
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].UserName < orgs[j].UserName })
+
+	return orgs, nil
+}
+
+func (f *fakeGitea) CreateOrganization(_ context.Context, o gitea.Organization) error {
+	f.nextID++
+	o.ID = f.nextID
+	f.orgs[strings.ToLower(o.UserName)] = &o
+
+	return nil
+}
+
+func (f *fakeGitea) DeleteOrganization(_ context.Context, orgname string) error {
+	delete(f.orgs, strings.ToLower(orgname))
+
+	return nil
+}
+
+func (f *fakeGitea) AdoptOrganization(_ context.Context, o *gitea.Organization) error {
+	f.orgs[strings.ToLower(o.UserName)].Description += " " + f.conf.SyncConfig.OwnershipMarker
+
+	return nil
+}
+
+func (f *fakeGitea) ListTeams(_ context.Context, orgname string) ([]*giteapkg.Team, error) {
+	var teams []*giteapkg.Team
+
+	for _, t := range f.teams {
+		if strings.EqualFold(t.org, orgname) {
+			teams = append(teams, t.team)
+		}
+	}
+
+	sort.Slice(teams, func(i, j int) bool { return teams[i].ID < teams[j].ID })
+
+	return teams, nil
+}
+
+func (f *fakeGitea) CreateTeam(_ context.Context, orgname string, team gitea.Team, opts gitea.CreateTeamOpts) error {
+	t := f.addTeam(orgname, team.Name, team.Description)
+	t.Permission, t.Units, t.IncludesAllRepositories = opts.Permission, opts.Units, opts.IncludesAllRepositories
+
+	return nil
+}
+
+func (f *fakeGitea) DeleteTeam(_ context.Context, teamID int64) error {
+	delete(f.teams, teamID)
+
+	return nil
+}
+
+func (f *fakeGitea) AdoptTeam(_ context.Context, t *gitea.Team) error {
+	f.teams[t.ID].team.Description += " " + f.conf.SyncConfig.OwnershipMarker
+
+	return nil
+}
+
+func (f *fakeGitea) ListTeamUsers(_ context.Context, teamID int64) (map[string]gitea.Account, error) {
+	accounts := make(map[string]gitea.Account)
+
+	for login := range f.teams[teamID].members {
+		u := f.users[login]
+		accounts[u.UserName] = gitea.Account{ID: int(u.ID), Login: u.UserName, SourceID: u.SourceID}
+	}
+
+	return accounts, nil
+}
+
+// AddUsersToTeam adds the users to the team. Like the Gitea client, it skips the users who don't exist.
+func (f *fakeGitea) AddUsersToTeam(_ context.Context, users []gitea.Account, team int64) error {
+	for _, u := range users {
+		if _, ok := f.users[strings.ToLower(u.Login)]; ok {
+			f.teams[team].members[strings.ToLower(u.Login)] = true
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeGitea) DelUsersFromTeam(_ context.Context, users []gitea.Account, team int64) error {
+	for _, u := range users {
+		delete(f.teams[team].members, strings.ToLower(u.Login))
+	}
+
+	return nil
+}
+
+func (f *fakeGitea) ListOrgRepositories(_ context.Context, orgname string) ([]string, error) {
+	return f.repos[strings.ToLower(orgname)], nil
+}
+
+func (f *fakeGitea) ListTeamRepositories(_ context.Context, teamID int64) ([]string, error) {
+	return f.teamRepos(teamID), nil
+}
+
+func (f *fakeGitea) AddTeamRepository(_ context.Context, teamID int64, _, repo string) error {
+	f.teams[teamID].repos[repo] = true
+
+	return nil
+}
+
+func (f *fakeGitea) RemoveTeamRepository(_ context.Context, teamID int64, _, repo string) error {
+	delete(f.teams[teamID].repos, repo)
+
+	return nil
+}
+
+func (f *fakeGitea) ListCollaborators(_ context.Context, owner, repo string) ([]string, error) {
+	collaborators, ok := f.collaborators[strings.ToLower(owner+"/"+repo)]
+	if !ok {
+		return nil, errors.Wrapf(gitea.ErrNotFound, "repository: %s/%s", owner, repo)
+	}
+
+	logins := make([]string, 0, len(collaborators))
+	for login := range collaborators {
+		logins = append(logins, login)
+	}
+
+	sort.Strings(logins)
+
+	return logins, nil
+}
+
+// AddCollaborator adds the collaborator. Like Gitea, it fails if the user does not exist.
+func (f *fakeGitea) AddCollaborator(
+	_ context.Context, owner, repo, login string, permission giteapkg.AccessMode,
+) error {
+	key := strings.ToLower(owner + "/" + repo)
+
+	if err := f.fail[key]; err != nil {
+		return err
+	}
+
+	if _, ok := f.collaborators[key]; !ok {
+		return errors.Errorf("404 Not Found: repository does not exist: %s", key)
+	}
+
+	if _, ok := f.users[strings.ToLower(login)]; !ok {
+		return errors.Errorf("404 Not Found: user does not exist: %s", login)
+	}
+
+	f.collaborators[key][login] = permission
+
+	return nil
+}
+
+func (f *fakeGitea) DeleteCollaborator(_ context.Context, owner, repo, login string) error {
+	key := strings.ToLower(owner + "/" + repo)
+
+	if err := f.fail[key]; err != nil {
+		return err
+	}
+
+	delete(f.collaborators[key], login)
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/gitea"
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+)
+
+// Import adopts the existing organizations and teams of every Gitea target which are found in the LDAP directory, so
+// they are managed by the sync. Objects not found in LDAP are left untouched. In dry-run mode the objects are only
+// logged.
+func (c *Client) Import(ctx context.Context, dryRun bool) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Config.RunTimeout)
+	defer cancel()
+
+	ldapDirectory, err := c.LDAP.GetDirectory(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range c.Config.GetTargets() {
+		if err := c.importTarget(ctx, t, ldapDirectory, dryRun); err != nil {
+			return errors.Wrapf(err, "target: %s", t.Name)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) importTarget(
+	ctx context.Context, t *config.Target, ldapDirectory *ldap.Directory, dryRun bool,
+) error {
+	tc := &Client{
+		Config: c.Config.ForTarget(t),
+		LDAP:   c.LDAP,
+		log:    c.log,
+	}
+
+	var err error
+
+	if tc.patterns, err = compilePatterns(tc.Config); err != nil {
+		return err
+	}
+
+	giteaClient, err := gitea.New(ctx, tc.Config)
+	if err != nil {
+		return err
+	}
+
+	tc.Gitea = giteaClient
+
+	giteaOrgs, err := giteaClient.ListOrganizations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, giteaOrg := range giteaOrgs {
+		org, ok := ldapDirectory.Organizations[giteaOrg.UserName]
+		if !ok || tc.protectedOrganization(giteaOrg.UserName) {
+			continue
+		}
+
+		if !giteaClient.IsManaged(giteaOrg.Description) {
+			c.log.Info().Msgf("Adopting organization: %s (target: %s, dry-run: %v)", giteaOrg.UserName, t.Name, dryRun)
+
+			if !dryRun {
+				if err := giteaClient.AdoptOrganization(ctx, giteaOrg); err != nil {
+					return err
+				}
+			}
+		}
+
+		giteaTeams, err := giteaClient.ListTeams(ctx, giteaOrg.UserName)
+		if err != nil {
+			return err
+		}
+
+		for _, giteaTeam := range giteaTeams {
+			if giteaTeam.Name == ldap.OwnersTeam || tc.protectedTeam(giteaOrg.UserName, giteaTeam.Name) {
+				continue
+			}
+
+			if _, ok := org.Teams[giteaTeam.Name]; !ok || giteaClient.IsManaged(giteaTeam.Description) {
+				continue
+			}
+
+			c.log.Info().Msgf(
+				"Adopting team: %s (organization: %s, target: %s, dry-run: %v)",
+				giteaTeam.Name, giteaOrg.UserName, t.Name, dryRun,
+			)
+
+			if !dryRun {
+				if err := giteaClient.AdoptTeam(ctx, giteaTeam); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+	"github.com/janosmiko/gitea-ldap-sync/internal/logger"
+)
+
+// Ownership tracks the users, organizations and teams found in the LDAP directory of every sync profile, so a
+// profile never deletes the objects managed by another profile. Until every profile has fetched its directory, the
+// objects of the pending profiles are unknown, so the deletions are skipped.
+type Ownership struct {
+	mu       sync.RWMutex
+	profiles []string
+	claims   map[string]map[string]struct{}
+	log      logger.Logger
+}
+
+func NewOwnership(profiles ...string) *Ownership {
+	return &Ownership{
+		profiles: profiles,
+		claims:   make(map[string]map[string]struct{}),
+		log:      logger.New().Tag("ownership"),
+	}
+}
+
+// SetProfiles updates the list of the profiles (eg.: after a config reload). The claims of the removed profiles are
+// dropped.
+func (o *Ownership) SetProfiles(profiles ...string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.profiles = profiles
+
+	for p := range o.claims {
+		if !slices.Contains(profiles, p) {
+			delete(o.claims, p)
+		}
+	}
+}
+
+// Claim records the objects in the directory of the profile.
+func (o *Ownership) Claim(profile string, dir *ldap.Directory) {
+	claims := make(map[string]struct{})
+
+	for name := range dir.Users {
+		claims[userKey(name)] = struct{}{}
+	}
+
+	for orgName, org := range dir.Organizations {
+		claims[orgKey(orgName)] = struct{}{}
+
+		for teamName := range org.Teams {
+			claims[teamKey(orgName, teamName)] = struct{}{}
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.claims[profile] = claims
+
+	for p, c := range o.claims {
+		if p == profile {
+			continue
+		}
+
+		for name := range dir.Organizations {
+			if _, ok := c[orgKey(name)]; ok {
+				o.log.Warn().Msgf("Organization is managed by multiple profiles: %s (profiles: %s, %s)", name, p, profile)
+			}
+		}
+	}
+}
+
+// otherOwner returns the reason why the profile must not delete the object or an empty string if it's not managed
+// by another profile.
+func (o *Ownership) otherOwner(profile, key string) string {
+	if o == nil {
+		return ""
+	}
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	for _, p := range o.profiles {
+		if p == profile {
+			continue
+		}
+
+		c, ok := o.claims[p]
+		if !ok {
+			return "directory of profile is not fetched yet: " + p
+		}
+
+		if _, ok := c[key]; ok {
+			return "managed by profile: " + p
+		}
+	}
+
+	return ""
+}
+
+func userKey(name string) string {
+	return "user:" + name
+}
+
+func orgKey(name string) string {
+	return "org:" + name
+}
+
+func teamKey(org, team string) string {
+	return "team:" + org + "/" + team
+}
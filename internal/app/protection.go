@@ -0,0 +1,122 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/stringslice"
+)
+
+// patterns are the compiled regular expressions of the exclude and the protected lists. A nil pattern matches nothing.
+type patterns struct {
+	excludeUsers           *regexp.Regexp
+	excludeGroups          *regexp.Regexp
+	excludeSubgroups       *regexp.Regexp
+	protectedUsers         *regexp.Regexp
+	protectedOrganizations *regexp.Regexp
+	protectedTeams         *regexp.Regexp
+}
+
+// compilePatterns compiles the regular expressions of the configuration once, so they are not compiled for every
+// user, team and organization.
+func compilePatterns(cfg *config.Config) (*patterns, error) {
+	p := &patterns{}
+	protected := cfg.SyncConfig.Protected
+
+	for _, e := range []struct {
+		key  string
+		expr string
+		re   **regexp.Regexp
+	}{
+		{"ldap.exclude_users_regex", cfg.LDAP.ExcludeUsersRegex, &p.excludeUsers},
+		{"ldap.exclude_groups_regex", cfg.LDAP.ExcludeGroupsRegex, &p.excludeGroups},
+		{"ldap.exclude_subgroups_regex", cfg.LDAP.ExcludeSubgroupsRegex, &p.excludeSubgroups},
+		{"sync_config.protected.users_regex", protected.UsersRegex, &p.protectedUsers},
+		{"sync_config.protected.organizations_regex", protected.OrganizationsRegex, &p.protectedOrganizations},
+		{"sync_config.protected.teams_regex", protected.TeamsRegex, &p.protectedTeams},
+	} {
+		if e.expr == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(e.expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: invalid regular expression", e.key)
+		}
+
+		*e.re = re
+	}
+
+	return p, nil
+}
+
+// protectedUser returns the reason why the Gitea user must not be deleted or removed from a team, or an empty string
+// if it's not protected. Besides the configured users, the user the sync is authenticated as and (optionally) the
+// users who don't belong to gitea.auth_source_id (the local users and the users of the other authentication sources)
+// are protected. They are only recognized if the server reports the authentication source of the users.
+func (c *Client) protectedUser(login string, sourceID int64) string {
+	if reason := c.protectedLogin(login); reason != "" {
+		return reason
+	}
+
+	if !c.Config.SyncConfig.Protected.LocalUsers || !c.Gitea.OtherAuthSource(sourceID) {
+		return ""
+	}
+
+	if sourceID == 0 {
+		return "local-user"
+	}
+
+	return "other-auth-source"
+}
+
+// protectedLogin returns the reason why the Gitea user must not be deleted or removed from a team or a repository
+// based on its login: the configured users and the user the sync is authenticated as are protected.
+func (c *Client) protectedLogin(login string) string {
+	if matchesAny(c.Config.SyncConfig.Protected.Users, c.patterns.protectedUsers, login) {
+		return "protected"
+	}
+
+	if me := c.Gitea.CurrentUser(); me != nil && strings.EqualFold(me.UserName, login) {
+		return "current-user"
+	}
+
+	if c.Config.Gitea.User != "" && strings.EqualFold(c.Config.Gitea.User, login) {
+		return "current-user"
+	}
+
+	return ""
+}
+
+// protectedOrganization reports whether the Gitea organization must be left untouched.
+func (c *Client) protectedOrganization(org string) bool {
+	return matchesAny(c.Config.SyncConfig.Protected.Organizations, c.patterns.protectedOrganizations, org)
+}
+
+// protectedTeam reports whether the Gitea team must be left untouched. Teams are matched by their name or by
+// <organization>/<team>.
+func (c *Client) protectedTeam(org, team string) bool {
+	return matchesAny(c.Config.SyncConfig.Protected.Teams, c.patterns.protectedTeams, team, org+"/"+team)
+}
+
+// matchesAny reports whether any of the candidates is one of the names or matches the regular expression.
+func matchesAny(names []string, re *regexp.Regexp, candidates ...string) bool {
+	for _, s := range candidates {
+		if s == "" {
+			continue
+		}
+
+		if stringslice.Contains(names, s) {
+			return true
+		}
+
+		if re != nil && re.MatchString(s) {
+			return true
+		}
+	}
+
+	return false
+}
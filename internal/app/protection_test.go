@@ -0,0 +1,74 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/app"
+)
+
+func TestProtectedUser(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		login      string
+		sourceID   int64
+		localUsers bool
+		want       string
+	}{
+		{
+			name:       "Test if a user of the authentication source is not protected",
+			login:      "jdoe",
+			sourceID:   1,
+			localUsers: true,
+			want:       "",
+		},
+		{
+			name:       "Test if a local user is protected",
+			login:      "bot",
+			sourceID:   0,
+			localUsers: true,
+			want:       "local-user",
+		},
+		{
+			name:       "Test if a user of another authentication source is protected",
+			login:      "oauth-user",
+			sourceID:   2,
+			localUsers: true,
+			want:       "other-auth-source",
+		},
+		{
+			name:     "Test if the users of the other authentication sources are not protected if disabled",
+			login:    "oauth-user",
+			sourceID: 2,
+			want:     "",
+		},
+		{
+			name:     "Test if the current user is always protected",
+			login:    "Admin",
+			sourceID: 1,
+			want:     "current-user",
+		},
+		{
+			name:     "Test if a configured user is protected",
+			login:    "root",
+			sourceID: 1,
+			want:     "protected",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf := newConfig()
+			conf.SyncConfig.Protected.Users = []string{"root"}
+			conf.SyncConfig.Protected.LocalUsers = tt.localUsers
+
+			c := app.NewTestClient(conf, newFakeGitea(t, conf))
+
+			if got := c.ProtectedUser(tt.login, tt.sourceID); got != tt.want {
+				t.Errorf("ProtectedUser() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
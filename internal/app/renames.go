@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+	"github.com/janosmiko/gitea-ldap-sync/internal/state"
+)
+
+// userNamesKey is the key of the Gitea user names of the LDAP users (by their immutable ID) in the state.
+func (c *Client) userNamesKey() string {
+	return "users/" + c.profile() + "/" + c.Config.TargetName
+}
+
+// renameUsers renames the Gitea users whose user name changed in LDAP since the last sync, so their accounts,
+// repositories and history are kept. The users are correlated by the immutable ldap.user_id_attribute, their last
+// known names are remembered in the state.
+func (c *Client) renameUsers(ctx context.Context, ldapDirectory *ldap.Directory) error {
+	if c.Config.LDAP.UserIDAttribute == "" {
+		return nil
+	}
+
+	store, err := state.Open(c.Config.StateFile)
+	if err != nil {
+		return err
+	}
+
+	// The Gitea user names by the ID of the LDAP users.
+	known := make(map[string]string)
+	if _, err := store.Get(c.userNamesKey(), &known); err != nil {
+		return err
+	}
+
+	users := make([]*ldap.User, 0, len(ldapDirectory.Users))
+	for _, u := range ldapDirectory.Users {
+		users = append(users, u)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+	names := make(map[string]string, len(users))
+
+	var renameErr error
+
+	for _, u := range users {
+		if u.ID == "" {
+			c.log.Debug().Msgf("User has no id, renames are not detected: %s", u.Name)
+
+			continue
+		}
+
+		if old, ok := known[u.ID]; ok && !strings.EqualFold(old, u.Name) {
+			renamed := false
+			if renameErr == nil {
+				renamed, renameErr = c.renameUser(ctx, old, u.Name)
+			}
+
+			// After a failure or a skipped rename the old names are kept, so the renames are retried on the next run.
+			if !renamed {
+				names[u.ID] = old
+
+				continue
+			}
+		}
+
+		names[u.ID] = u.Name
+	}
+
+	if err := store.Set(c.userNamesKey(), names); err != nil {
+		return err
+	}
+
+	return renameErr
+}
+
+// renameUser renames the Gitea user if it's managed by the sync and the new name is not used by another user. It
+// reports whether the user goes by the new name in Gitea (it's renamed or the old user doesn't exist).
+func (c *Client) renameUser(ctx context.Context, oldName, newName string) (bool, error) {
+	if caps := c.Gitea.Capabilities(); !caps.UserRename() {
+		c.report.Skipped++
+		c.log.Warn().Msgf("User is not renamed (reason: not supported by the server: %s): %s to %s", caps, oldName, newName)
+
+		return false, nil
+	}
+
+	if reason := c.protectedLogin(oldName); reason != "" {
+		c.report.Skipped++
+		c.log.Info().Msgf("User is not renamed (reason: %s): %s to %s", reason, oldName, newName)
+
+		return false, nil
+	}
+
+	existing, err := c.Gitea.GetUser(ctx, oldName)
+	if err != nil {
+		return false, err
+	}
+
+	if existing == nil {
+		c.log.Debug().Msgf("User is not renamed (reason: does not exist in gitea): %s to %s", oldName, newName)
+
+		return true, nil
+	}
+
+	if c.Gitea.OtherAuthSource(existing.SourceID) {
+		c.report.Skipped++
+		c.log.Warn().Msgf("User is not renamed (reason: other-auth-source): %s to %s", oldName, newName)
+
+		return false, nil
+	}
+
+	taken, err := c.Gitea.GetUser(ctx, newName)
+	if err != nil {
+		return false, err
+	}
+
+	if taken != nil {
+		c.report.Skipped++
+		c.log.Warn().Msgf("User is not renamed (reason: name is already used): %s to %s", oldName, newName)
+
+		return false, nil
+	}
+
+	if err := c.Gitea.RenameUser(ctx, oldName, newName); err != nil {
+		return false, err
+	}
+
+	c.report.UsersRenamed++
+
+	return true, nil
+}
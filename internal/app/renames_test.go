@@ -0,0 +1,118 @@
+package app_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/app"
+	"github.com/janosmiko/gitea-ldap-sync/internal/gitea"
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+)
+
+func TestRenameUsers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		version     string
+		existing    []string
+		want        []string
+		wantRenamed int
+		wantSkipped int
+	}{
+		{
+			name:        "Test if the user is renamed if the user name changed in ldap",
+			existing:    []string{"jdoe"},
+			want:        []string{"john"},
+			wantRenamed: 1,
+		},
+		{
+			name:        "Test if the user is not renamed if the new name is already used",
+			existing:    []string{"jdoe", "john"},
+			want:        []string{"jdoe", "john"},
+			wantSkipped: 1,
+		},
+		{
+			name:        "Test if the user is not renamed if the server doesn't support renames",
+			version:     "1.19.4",
+			existing:    []string{"jdoe"},
+			want:        []string{"jdoe"},
+			wantSkipped: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf := newConfig()
+			conf.LDAP.UserIDAttribute = "entryUUID"
+			conf.StateFile = filepath.Join(t.TempDir(), "state.json")
+
+			f := newFakeGitea(t, conf)
+			if tt.version != "" {
+				caps, err := gitea.ParseServerVersion(tt.version, false)
+				if err != nil {
+					t.Fatalf("ParseServerVersion() error = %v", err)
+				}
+
+				f.caps = caps
+			}
+
+			for _, login := range tt.existing {
+				f.addUser(login, 1)
+			}
+
+			ctx := context.Background()
+
+			for _, name := range []string{"jdoe", "john"} {
+				users := ldapUsers(name)
+				users[name].ID = "4f0c2b9e"
+
+				c := app.NewTestClient(conf, f)
+				if err := c.RenameUsers(ctx, &ldap.Directory{Users: users}); err != nil {
+					t.Fatalf("RenameUsers() error = %v", err)
+				}
+
+				if name == "jdoe" {
+					continue
+				}
+
+				if got := c.Report().UsersRenamed; got != tt.wantRenamed {
+					t.Errorf("UsersRenamed = %d, want %d", got, tt.wantRenamed)
+				}
+
+				if got := c.Report().Skipped; got != tt.wantSkipped {
+					t.Errorf("Skipped = %d, want %d", got, tt.wantSkipped)
+				}
+			}
+
+			for _, login := range []string{"jdoe", "john"} {
+				_, got := f.users[login]
+				if want := slices.Contains(tt.want, login); got != want {
+					t.Errorf("user %s exists = %v, want %v", login, got, want)
+				}
+			}
+
+			if tt.wantSkipped == 0 {
+				return
+			}
+
+			// The skipped rename is retried on the next run, once it's possible.
+			f.caps = newFakeGitea(t, conf).caps
+			delete(f.users, "john")
+
+			users := ldapUsers("john")
+			users["john"].ID = "4f0c2b9e"
+
+			if err := app.NewTestClient(conf, f).RenameUsers(ctx, &ldap.Directory{Users: users}); err != nil {
+				t.Fatalf("RenameUsers() error = %v", err)
+			}
+
+			if _, ok := f.users["jdoe"]; ok {
+				t.Error("user jdoe is not renamed on the next run")
+			}
+		})
+	}
+}
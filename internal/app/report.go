@@ -0,0 +1,57 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Report summarizes the changes made by a sync run of a profile in a Gitea target.
+type Report struct {
+	Profile  string        `json:"profile"`
+	Target   string        `json:"target"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+
+	UsersSynced          int `json:"users_synced"`
+	UsersDeleted         int `json:"users_deleted"`
+	UsersRenamed         int `json:"users_renamed"`
+	OrganizationsSynced  int `json:"organizations_synced"`
+	OrganizationsDeleted int `json:"organizations_deleted"`
+	TeamsSynced          int `json:"teams_synced"`
+	TeamsDeleted         int `json:"teams_deleted"`
+	MembersAdded         int `json:"members_added"`
+	MembersRemoved       int `json:"members_removed"`
+	RepositoriesAdded    int `json:"repositories_added"`
+	RepositoriesRemoved  int `json:"repositories_removed"`
+	CollaboratorsAdded   int `json:"collaborators_added"`
+	CollaboratorsRemoved int `json:"collaborators_removed"`
+	Skipped              int `json:"skipped"`
+	// NameCollisions is the number of LDAP entries skipped, because their Gitea names are used by other entries.
+	NameCollisions int `json:"name_collisions"`
+}
+
+// String returns the report in JSON format, so it can be published as an expvar.
+func (r *Report) String() string {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "{}"
+	}
+
+	return string(b)
+}
+
+// Summary returns the report in a human-readable format.
+func (r *Report) Summary() string {
+	return fmt.Sprintf(
+		"profile: %s, target: %s, duration: %s, users synced: %d, users deleted: %d, users renamed: %d, "+
+			"organizations synced: %d, organizations deleted: %d, teams synced: %d, teams deleted: %d, "+
+			"members added: %d, members removed: %d, repositories added: %d, repositories removed: %d, "+
+			"collaborators added: %d, collaborators removed: %d, skipped: %d, name collisions: %d",
+		r.Profile, r.Target, r.Duration.Round(time.Millisecond), r.UsersSynced, r.UsersDeleted, r.UsersRenamed,
+		r.OrganizationsSynced, r.OrganizationsDeleted, r.TeamsSynced, r.TeamsDeleted, r.MembersAdded, r.MembersRemoved,
+		r.RepositoriesAdded, r.RepositoriesRemoved, r.CollaboratorsAdded, r.CollaboratorsRemoved, r.Skipped,
+		r.NameCollisions,
+	)
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	giteapkg "code.gitea.io/sdk/gitea"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+)
+
+// managesRepositories reports whether the repositories of the teams are synced.
+func (c *Client) managesRepositories() bool {
+	return c.Config.LDAP.SubgroupRepositoriesAttribute != "" || len(c.Config.SyncConfig.TeamRepositories) != 0
+}
+
+// repositoryPatterns returns the repository patterns of the team from its LDAP entry and from the config. It reports
+// whether the repositories of the team are managed: the team has the repositories attribute or matches a
+// team_repositories rule.
+func (c *Client) repositoryPatterns(org string, ldapTeam *ldap.Team) ([]string, bool) {
+	var (
+		patterns []string
+		managed  bool
+	)
+
+	if attr := c.Config.LDAP.SubgroupRepositoriesAttribute; attr != "" && ldapTeam.Entry != nil {
+		values := ldapTeam.GetAttributeValues(attr)
+		patterns = append(patterns, values...)
+		managed = len(values) != 0
+	}
+
+	for _, tr := range c.Config.SyncConfig.TeamRepositories {
+		if ok, _ := path.Match(strings.ToLower(tr.Team), strings.ToLower(org+"/"+ldapTeam.Name)); ok {
+			patterns = append(patterns, tr.Repositories...)
+			managed = true
+		}
+	}
+
+	return patterns, managed
+}
+
+// syncTeamRepositories grants the team access to the repositories of the organization matching its patterns and
+// revokes its access to the other repositories. Teams with access to all repositories and teams without repository
+// patterns are skipped, their repositories are managed in Gitea.
+func (c *Client) syncTeamRepositories(
+	ctx context.Context, org string, ldapTeam *ldap.Team, giteaTeam *giteapkg.Team, orgRepos []string,
+) error {
+	if giteaTeam.IncludesAllRepositories {
+		c.log.Debug().Msgf("Team repositories skipped (reason: includes all repositories): %s", giteaTeam.Name)
+
+		return nil
+	}
+
+	patterns, managed := c.repositoryPatterns(org, ldapTeam)
+	if !managed {
+		c.log.Debug().Msgf("Team repositories skipped (reason: no repository patterns): %s", giteaTeam.Name)
+
+		return nil
+	}
+
+	want := make(map[string]string)
+
+	for _, repo := range orgRepos {
+		for _, p := range patterns {
+			if ok, _ := path.Match(strings.ToLower(p), strings.ToLower(repo)); ok {
+				want[strings.ToLower(repo)] = repo
+
+				break
+			}
+		}
+	}
+
+	current, err := c.Gitea.ListTeamRepositories(ctx, giteaTeam.ID)
+	if err != nil {
+		return err
+	}
+
+	has := make(map[string]struct{}, len(current))
+
+	for _, repo := range current {
+		has[strings.ToLower(repo)] = struct{}{}
+
+		if _, ok := want[strings.ToLower(repo)]; ok {
+			continue
+		}
+
+		if err := c.Gitea.RemoveTeamRepository(ctx, giteaTeam.ID, org, repo); err != nil {
+			return err
+		}
+
+		c.report.RepositoriesRemoved++
+	}
+
+	for key, repo := range want {
+		if _, ok := has[key]; ok {
+			continue
+		}
+
+		if err := c.Gitea.AddTeamRepository(ctx, giteaTeam.ID, org, repo); err != nil {
+			return err
+		}
+
+		c.report.RepositoriesAdded++
+	}
+
+	return nil
+}
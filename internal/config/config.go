@@ -1,41 +1,107 @@
 package config
 
 import (
+	"fmt"
+	"reflect"
 	"strings"
+	"time"
+	"unicode"
 
 	"code.gitea.io/sdk/gitea"
+	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
-
-	"github.com/janosmiko/gitea-ldap-sync/internal/logger"
 )
 
 // Config describes the settings of the application. This structure is used in the settings-import process.
 type Config struct {
-	Gitea       *GiteaConfig `mapstructure:"gitea"`
-	LDAP        *LDAPConfig  `mapstructure:"ldap"`
-	SyncConfig  *SyncConfig  `mapstructure:"sync_config"`
-	CronTimer   string       `mapstructure:"cron_timer"`
-	CronEnabled bool         `mapstructure:"cron_enabled"`
+	Gitea       *GiteaConfig  `mapstructure:"gitea"`
+	LDAP        *LDAPConfig   `mapstructure:"ldap"`
+	SyncConfig  *SyncConfig   `mapstructure:"sync_config"`
+	CronTimer   string        `mapstructure:"cron_timer"`
+	CronEnabled bool          `mapstructure:"cron_enabled"`
+	RunTimeout  time.Duration `mapstructure:"run_timeout"`
+	Retry       *RetryConfig  `mapstructure:"retry"`
+
+	MetricsListenAddress string `mapstructure:"metrics_listen_address"`
+
+	// StateFile stores the data the sync has to remember between the runs. Kept in memory if empty.
+	StateFile string `mapstructure:"state_file"`
+
+	Vault *VaultConfig `mapstructure:"vault"`
+
+	Profiles []*Profile `mapstructure:"profiles"`
+	Targets  []*Target  `mapstructure:"targets"`
+
+	// ProfileName and TargetName are the names of the profile and the Gitea target the configuration was built for
+	// (see ForProfile and ForTarget).
+	ProfileName string `mapstructure:"-"`
+	TargetName  string `mapstructure:"-"`
+}
+
+type VaultConfig struct {
+	Address   string `mapstructure:"address"`
+	Token     string `mapstructure:"token"`
+	TokenFile string `mapstructure:"token_file"`
+	Namespace string `mapstructure:"namespace"`
+	Mount     string `mapstructure:"mount"`
+	KVVersion int    `mapstructure:"kv_version"`
+}
+
+type RetryConfig struct {
+	MaxAttempts      int           `mapstructure:"max_attempts"`
+	InitialBackoff   time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff       time.Duration `mapstructure:"max_backoff"`
+	GiteaStatusCodes []int         `mapstructure:"gitea_status_codes"`
+	LDAPResultCodes  []int         `mapstructure:"ldap_result_codes"`
 }
 
 type GiteaConfig struct {
-	User         string `mapstructure:"user"`
-	Token        string `mapstructure:"token"`
-	BaseURL      string `mapstructure:"base_url"`
-	AuthSourceID int64  `mapstructure:"auth_source_id"`
+	AuthMethod    string `mapstructure:"auth_method"`
+	User          string `mapstructure:"user"`
+	Token         string `mapstructure:"token"`
+	TokenFile     string `mapstructure:"token_file"`
+	Password      string `mapstructure:"password"`
+	PasswordFile  string `mapstructure:"password_file"`
+	Sudo          string `mapstructure:"sudo"`
+	BaseURL       string `mapstructure:"base_url"`
+	AuthSourceID  int64  `mapstructure:"auth_source_id"`
+	ClientTimeout int    `mapstructure:"client_timeout"`
+
+	CAFile           string            `mapstructure:"ca_file"`
+	AllowInsecureTLS bool              `mapstructure:"allow_insecure_tls"`
+	ProxyURL         string            `mapstructure:"proxy_url"`
+	Headers          map[string]string `mapstructure:"headers"`
 }
 
 type LDAPConfig struct {
 	URL              string `mapstructure:"url"`
+	SRVDomain        string `mapstructure:"srv_domain"`
 	Port             int    `mapstructure:"port"`
 	UseTLS           bool   `mapstructure:"use_tls"`
 	AllowInsecureTLS bool   `mapstructure:"allow_insecure_tls"`
+	TLSMode          string `mapstructure:"tls_mode"`
+	TLSMinVersion    string `mapstructure:"tls_min_version"`
+	CAFile           string `mapstructure:"ca_file"`
+	ClientCertFile   string `mapstructure:"client_cert_file"`
+	ClientKeyFile    string `mapstructure:"client_key_file"`
+	ServerName       string `mapstructure:"server_name"`
+	BindMethod       string `mapstructure:"bind_method"`
 	BindDN           string `mapstructure:"bind_dn"`
 	BindPassword     string `mapstructure:"bind_password"`
-	UserFilter       string `mapstructure:"user_filter"`
-	UserSearchBase   string `mapstructure:"user_search_base"`
+	BindPasswordFile string `mapstructure:"bind_password_file"`
+
+	KerberosUsername   string `mapstructure:"kerberos_username"`
+	KerberosRealm      string `mapstructure:"kerberos_realm"`
+	KerberosKeytabFile string `mapstructure:"kerberos_keytab_file"`
+	KerberosConfigFile string `mapstructure:"kerberos_config_file"`
+	KerberosSPN        string `mapstructure:"kerberos_spn"`
+
+	ConnectTimeout   time.Duration `mapstructure:"connect_timeout"`
+	OperationTimeout time.Duration `mapstructure:"operation_timeout"`
+	UserFilter       string        `mapstructure:"user_filter"`
+	UserSearchBase   string        `mapstructure:"user_search_base"`
 
 	UserUsernameAttribute     string `mapstructure:"user_username_attribute"`
 	UserFullNameAttribute     string `mapstructure:"user_fullname_attribute"`
@@ -44,6 +110,9 @@ type LDAPConfig struct {
 	UserEmailAttribute        string `mapstructure:"user_email_attribute"`
 	UserPublicSSHKeyAttribute string `mapstructure:"user_public_ssh_key_attribute"`
 	UserAvatarAttribute       string `mapstructure:"user_avatar_attribute"`
+	// UserIDAttribute is an immutable attribute of the users (eg.: objectGUID, entryUUID). If it's set, the users
+	// whose user name changed in LDAP are renamed in Gitea instead of being recreated. Requires StateFile.
+	UserIDAttribute string `mapstructure:"user_id_attribute"`
 
 	ExcludeUsers      []string `mapstructure:"exclude_users"`
 	ExcludeUsersRegex string   `mapstructure:"exclude_users_regex"`
@@ -69,12 +138,101 @@ type LDAPConfig struct {
 
 	TrimParentName    bool   `mapstructure:"trim_parent_name"`
 	SubgroupSeparator string `mapstructure:"subgroup_separator"`
+
+	// The templates (text/template) of the names and the descriptions of the organizations and the teams. If a
+	// template is empty, the value of the matching attribute is used.
+	GroupNameTemplate           string `mapstructure:"group_name_template"`
+	GroupFullNameTemplate       string `mapstructure:"group_fullname_template"`
+	GroupDescriptionTemplate    string `mapstructure:"group_description_template"`
+	SubgroupNameTemplate        string `mapstructure:"subgroup_name_template"`
+	SubgroupDescriptionTemplate string `mapstructure:"subgroup_description_template"`
+
+	// The members of the owners group of an organization are synced to its Owners team. The owners group is referenced
+	// by the DN in OwnersGroupAttribute of the organization group or named <organization><OwnersGroupSuffix>.
+	OwnersGroupAttribute string `mapstructure:"owners_group_attribute"`
+	OwnersGroupSuffix    string `mapstructure:"owners_group_suffix"`
+
+	// DefaultTeam is created in every organization, its members are the direct members of the organization group.
+	DefaultTeam string `mapstructure:"default_team"`
+
+	// SubgroupRepositoriesAttribute lists the repositories (names or glob patterns) of the team.
+	SubgroupRepositoriesAttribute string `mapstructure:"subgroup_repositories_attribute"`
+}
+
+// Authentication methods of the Gitea API.
+const (
+	AuthMethodToken = "token"
+	AuthMethodBasic = "basic"
+)
+
+func (c *GiteaConfig) GetAuthMethod() string {
+	if c.AuthMethod == "" {
+		return AuthMethodToken
+	}
+
+	return strings.ToLower(c.AuthMethod)
+}
+
+// TLS modes of the LDAP connection.
+const (
+	TLSModeNone     = "none"
+	TLSModeLDAPS    = "ldaps"
+	TLSModeStartTLS = "starttls"
+)
+
+// GetTLSMode returns the configured TLS mode. If it's not set, it falls back to the legacy use_tls setting.
+func (c *LDAPConfig) GetTLSMode() string {
+	if c.TLSMode != "" {
+		return strings.ToLower(c.TLSMode)
+	}
+
+	if c.UseTLS {
+		return TLSModeLDAPS
+	}
+
+	return TLSModeNone
+}
+
+// Bind methods of the LDAP connection.
+const (
+	BindMethodSimple   = "simple"
+	BindMethodExternal = "external"
+	BindMethodGSSAPI   = "gssapi"
+)
+
+func (c *LDAPConfig) GetBindMethod() string {
+	if c.BindMethod == "" {
+		return BindMethodSimple
+	}
+
+	return strings.ToLower(c.BindMethod)
 }
 
 type SyncConfig struct {
-	CreateGroups bool `mapstructure:"create_groups"`
-	FullSync     bool `mapstructure:"full_sync"`
-	Defaults     struct {
+	CreateGroups    bool   `mapstructure:"create_groups"`
+	FullSync        bool   `mapstructure:"full_sync"`
+	OwnershipMarker string `mapstructure:"ownership_marker"`
+	// MigrateLocalUsers moves the local users with the same username and email address as an LDAP user to the
+	// authentication source.
+	MigrateLocalUsers bool `mapstructure:"migrate_local_users"`
+	// TeamRepositories grant the teams access to the repositories of their organization.
+	TeamRepositories []*TeamRepositories `mapstructure:"team_repositories"`
+	// Collaborators grant the members of LDAP groups access to repositories as collaborators.
+	Collaborators []*Collaborators `mapstructure:"collaborators"`
+	// Protected objects are never deleted and their members are never removed by the sync.
+	Protected struct {
+		Users              []string `mapstructure:"users"`
+		UsersRegex         string   `mapstructure:"users_regex"`
+		Organizations      []string `mapstructure:"organizations"`
+		OrganizationsRegex string   `mapstructure:"organizations_regex"`
+		// Teams are matched by their name or by <organization>/<team>.
+		Teams      []string `mapstructure:"teams"`
+		TeamsRegex string   `mapstructure:"teams_regex"`
+		// LocalUsers protects the users which don't belong to gitea.auth_source_id: the local users and the users of the
+		// other authentication sources.
+		LocalUsers bool `mapstructure:"local_users"`
+	} `mapstructure:"protected"`
+	Defaults struct {
 		Organization struct {
 			RepoAdminChangeTeamAccess bool   `mapstructure:"repo_admin_change_team_access"`
 			Visibility                string `mapstructure:"visibility"`
@@ -93,31 +251,65 @@ type SyncConfig struct {
 	} `mapstructure:"defaults"`
 }
 
+// TeamRepositories grants the teams matching Team (<organization>/<team>, glob patterns are allowed) access to the
+// repositories matching Repositories (names or glob patterns).
+type TeamRepositories struct {
+	Team         string   `mapstructure:"team"`
+	Repositories []string `mapstructure:"repositories"`
+}
+
+// Collaborators grants the users of Group (<organization> or <organization>/<team>) access to Repository
+// (<owner>/<repository>) with Permission (read, write or admin).
+type Collaborators struct {
+	Group      string           `mapstructure:"group"`
+	Repository string           `mapstructure:"repository"`
+	Permission gitea.AccessMode `mapstructure:"permission"`
+}
+
 func New() (*Config, error) {
-	logger.Configure()
+	return Load("")
+}
+
+// Load reads the configuration from the given file (or from the default locations if path is empty), the environment
+// variables and the defaults. Unknown keys in the config file are rejected. The logger is not configured here, as the
+// configuration is reloaded while the syncs are running.
+func Load(path string) (*Config, error) {
+	viper.Reset()
 
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("/etc/gitea-ldap-sync")
-	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 
-	// viper.SetTypeByDefaultValue(true)
+	if path != "" {
+		viper.SetConfigFile(path)
+	} else {
+		viper.AddConfigPath(".")
+		viper.AddConfigPath("/etc/gitea-ldap-sync")
+		viper.SetConfigName("config")
+	}
+
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
-	_ = viper.ReadInConfig()
 
-	viper.SetTypeByDefaultValue(true)
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if path != "" || !errors.As(err, &notFound) {
+			return nil, errors.Wrap(err, "reading config file")
+		}
+	}
 
 	setDefaults()
 	setBinds()
 
-	for _, v := range viper.AllKeys() {
-		log.Debug().Str("tag", "[config]").Msgf("%s: %s", v, viper.GetString(v))
+	for _, s := range Effective() {
+		log.Debug().Str("tag", "[config]").Msgf("%s: %s (source: %s)", s.Key, s.Value, s.Source)
 	}
 
 	cfg := &Config{}
 
-	if err := viper.Unmarshal(cfg); err != nil {
+	if err := viper.UnmarshalExact(cfg, viper.DecodeHook(decodeHook())); err != nil {
+		return nil, errors.Wrap(err, "decoding configuration")
+	}
+
+	if err := loadProfiles(cfg); err != nil {
 		return nil, err
 	}
 
@@ -128,18 +320,61 @@ func New() (*Config, error) {
 	return cfg, nil
 }
 
+func decodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		stringToSliceHook,
+	)
+}
+
+// stringToSliceHook splits the lists set as a string (eg.: in environment variables) by commas and whitespaces.
+func stringToSliceHook(from, to reflect.Kind, data interface{}) (interface{}, error) {
+	if from != reflect.String || to != reflect.Slice {
+		return data, nil
+	}
+
+	return strings.FieldsFunc(data.(string), func(r rune) bool { //nolint:forcetypeassert
+		return r == ',' || unicode.IsSpace(r)
+	}), nil
+}
+
 //nolint:funlen
 func setBinds() {
 	_ = viper.BindEnv("gitea.base_url")
+	_ = viper.BindEnv("gitea.auth_method")
 	_ = viper.BindEnv("gitea.user")
 	_ = viper.BindEnv("gitea.token")
+	_ = viper.BindEnv("gitea.token_file")
+	_ = viper.BindEnv("gitea.password")
+	_ = viper.BindEnv("gitea.password_file")
+	_ = viper.BindEnv("gitea.sudo")
 	_ = viper.BindEnv("gitea.auth_source_id")
+	_ = viper.BindEnv("gitea.client_timeout")
+	_ = viper.BindEnv("gitea.ca_file")
+	_ = viper.BindEnv("gitea.allow_insecure_tls")
+	_ = viper.BindEnv("gitea.proxy_url")
 	_ = viper.BindEnv("ldap.url")
+	_ = viper.BindEnv("ldap.srv_domain")
 	_ = viper.BindEnv("ldap.port")
 	_ = viper.BindEnv("ldap.use_tls")
 	_ = viper.BindEnv("ldap.allow_insecure_tls")
+	_ = viper.BindEnv("ldap.tls_mode")
+	_ = viper.BindEnv("ldap.tls_min_version")
+	_ = viper.BindEnv("ldap.ca_file")
+	_ = viper.BindEnv("ldap.client_cert_file")
+	_ = viper.BindEnv("ldap.client_key_file")
+	_ = viper.BindEnv("ldap.server_name")
+	_ = viper.BindEnv("ldap.bind_method")
 	_ = viper.BindEnv("ldap.bind_dn")
 	_ = viper.BindEnv("ldap.bind_password")
+	_ = viper.BindEnv("ldap.bind_password_file")
+	_ = viper.BindEnv("ldap.kerberos_username")
+	_ = viper.BindEnv("ldap.kerberos_realm")
+	_ = viper.BindEnv("ldap.kerberos_keytab_file")
+	_ = viper.BindEnv("ldap.kerberos_config_file")
+	_ = viper.BindEnv("ldap.kerberos_spn")
+	_ = viper.BindEnv("ldap.connect_timeout")
+	_ = viper.BindEnv("ldap.operation_timeout")
 	_ = viper.BindEnv("ldap.user_filter")
 	_ = viper.BindEnv("ldap.user_search_base")
 	_ = viper.BindEnv("ldap.user_username_attribute")
@@ -149,6 +384,7 @@ func setBinds() {
 	_ = viper.BindEnv("ldap.user_email_attribute")
 	_ = viper.BindEnv("ldap.user_public_ssh_key_attribute")
 	_ = viper.BindEnv("ldap.user_avatar_attribute")
+	_ = viper.BindEnv("ldap.user_id_attribute")
 	_ = viper.BindEnv("ldap.exclude_users")
 	_ = viper.BindEnv("ldap.group_filter")
 	_ = viper.BindEnv("ldap.group_search_base")
@@ -165,10 +401,42 @@ func setBinds() {
 	_ = viper.BindEnv("ldap.exclude_subgroups_regex")
 	_ = viper.BindEnv("ldap.trim_parent_name")
 	_ = viper.BindEnv("ldap.subgroup_separator")
+	_ = viper.BindEnv("ldap.owners_group_attribute")
+	_ = viper.BindEnv("ldap.owners_group_suffix")
+	_ = viper.BindEnv("ldap.group_name_template")
+	_ = viper.BindEnv("ldap.group_fullname_template")
+	_ = viper.BindEnv("ldap.group_description_template")
+	_ = viper.BindEnv("ldap.subgroup_name_template")
+	_ = viper.BindEnv("ldap.subgroup_description_template")
+	_ = viper.BindEnv("ldap.default_team")
+	_ = viper.BindEnv("ldap.subgroup_repositories_attribute")
 	_ = viper.BindEnv("cron_timer")
 	_ = viper.BindEnv("cron_enabled")
+	_ = viper.BindEnv("run_timeout")
+	_ = viper.BindEnv("retry.max_attempts")
+	_ = viper.BindEnv("retry.initial_backoff")
+	_ = viper.BindEnv("retry.max_backoff")
+	_ = viper.BindEnv("retry.gitea_status_codes")
+	_ = viper.BindEnv("retry.ldap_result_codes")
+	_ = viper.BindEnv("metrics_listen_address")
+	_ = viper.BindEnv("state_file")
+	_ = viper.BindEnv("vault.address", "VAULT_ADDRESS", "VAULT_ADDR")
+	_ = viper.BindEnv("vault.token")
+	_ = viper.BindEnv("vault.token_file")
+	_ = viper.BindEnv("vault.namespace")
+	_ = viper.BindEnv("vault.mount")
+	_ = viper.BindEnv("vault.kv_version")
 	_ = viper.BindEnv("sync_config.create_groups")
 	_ = viper.BindEnv("sync_config.full_sync")
+	_ = viper.BindEnv("sync_config.ownership_marker")
+	_ = viper.BindEnv("sync_config.migrate_local_users")
+	_ = viper.BindEnv("sync_config.protected.users")
+	_ = viper.BindEnv("sync_config.protected.users_regex")
+	_ = viper.BindEnv("sync_config.protected.organizations")
+	_ = viper.BindEnv("sync_config.protected.organizations_regex")
+	_ = viper.BindEnv("sync_config.protected.teams")
+	_ = viper.BindEnv("sync_config.protected.teams_regex")
+	_ = viper.BindEnv("sync_config.protected.local_users")
 	_ = viper.BindEnv("sync_config.defaults.user.allow_create_organization")
 	_ = viper.BindEnv("sync_config.defaults.user.max_repo_creation")
 	_ = viper.BindEnv("sync_config.defaults.user.visibility")
@@ -182,15 +450,35 @@ func setBinds() {
 
 //nolint:funlen
 func setDefaults() {
+	viper.SetDefault("gitea.auth_method", "token")
 	viper.SetDefault("gitea.user", "")
 	viper.SetDefault("gitea.token", "")
+	viper.SetDefault("gitea.token_file", "")
+	viper.SetDefault("gitea.password", "")
+	viper.SetDefault("gitea.password_file", "")
+	viper.SetDefault("gitea.sudo", "")
 	viper.SetDefault("gitea.client_timeout", 10) //nolint:mnd
+	viper.SetDefault("gitea.ca_file", "")
+	viper.SetDefault("gitea.allow_insecure_tls", false)
+	viper.SetDefault("gitea.proxy_url", "")
 	viper.SetDefault("ldap.exclude_users", []string{"root"})
 	viper.SetDefault("ldap.exclude_groups", []string{""})
 	viper.SetDefault("ldap.exclude_subgroups", []string{""})
+	viper.SetDefault("ldap.srv_domain", "")
 	viper.SetDefault("ldap.port", "389")
 	viper.SetDefault("ldap.use_tls", true)
-	viper.SetDefault("ldap.allow_insecure_tls", true)
+	viper.SetDefault("ldap.allow_insecure_tls", false)
+	viper.SetDefault("ldap.tls_mode", "")
+	viper.SetDefault("ldap.tls_min_version", "1.2")
+	viper.SetDefault("ldap.ca_file", "")
+	viper.SetDefault("ldap.client_cert_file", "")
+	viper.SetDefault("ldap.client_key_file", "")
+	viper.SetDefault("ldap.server_name", "")
+	viper.SetDefault("ldap.bind_method", "simple")
+	viper.SetDefault("ldap.kerberos_config_file", "/etc/krb5.conf")
+	viper.SetDefault("ldap.kerberos_spn", "")
+	viper.SetDefault("ldap.connect_timeout", "10s")
+	viper.SetDefault("ldap.operation_timeout", "1m")
 	viper.SetDefault("ldap.user_username_attribute", "sAMAccountName")
 	viper.SetDefault("ldap.user_fullname_attribute", "cn")
 	viper.SetDefault("ldap.user_first_name_attribute", "name")
@@ -198,6 +486,7 @@ func setDefaults() {
 	viper.SetDefault("ldap.user_email_attribute", "mail")
 	viper.SetDefault("ldap.user_public_ssh_key_attribute", "sshPublicKey")
 	viper.SetDefault("ldap.user_avatar_attribute", "avatar")
+	viper.SetDefault("ldap.user_id_attribute", "")
 	viper.SetDefault("ldap.admin_filter", "")
 	viper.SetDefault("ldap.restricted_filter", "")
 	viper.SetDefault("ldap.trim_parent_name", false)
@@ -207,13 +496,45 @@ func setDefaults() {
 	viper.SetDefault("ldap.subgroup_name_attribute", "cn")
 	viper.SetDefault("ldap.subgroup_description_attribute", "cn")
 	viper.SetDefault("ldap.subgroup_separator", "/")
+	viper.SetDefault("ldap.owners_group_attribute", "")
+	viper.SetDefault("ldap.owners_group_suffix", "")
+	viper.SetDefault("ldap.group_name_template", "")
+	viper.SetDefault("ldap.group_fullname_template", "")
+	viper.SetDefault("ldap.group_description_template", "")
+	viper.SetDefault("ldap.subgroup_name_template", "")
+	viper.SetDefault("ldap.subgroup_description_template", "")
+	viper.SetDefault("ldap.default_team", "")
+	viper.SetDefault("ldap.subgroup_repositories_attribute", "")
 	viper.SetDefault("ldap.exclude_users_regex", "")
 	viper.SetDefault("ldap.exclude_groups_regex", "")
 	viper.SetDefault("ldap.exclude_subgroups_regex", "")
 	viper.SetDefault("cron_timer", "@every 1m")
 	viper.SetDefault("cron_enabled", true)
+	viper.SetDefault("run_timeout", "30m")
+	viper.SetDefault("retry.max_attempts", 3) //nolint:mnd
+	viper.SetDefault("retry.initial_backoff", "1s")
+	viper.SetDefault("retry.max_backoff", "30s")
+	viper.SetDefault("retry.gitea_status_codes", "429,502,503,504")
+	viper.SetDefault("retry.ldap_result_codes", "51,52")
+	viper.SetDefault("metrics_listen_address", "")
+	viper.SetDefault("state_file", "")
+	viper.SetDefault("vault.address", "")
+	viper.SetDefault("vault.token", "")
+	viper.SetDefault("vault.token_file", "")
+	viper.SetDefault("vault.namespace", "")
+	viper.SetDefault("vault.mount", "secret")
+	viper.SetDefault("vault.kv_version", 2) //nolint:mnd
 	viper.SetDefault("sync_config.create_groups", true)
 	viper.SetDefault("sync_config.full_sync", false)
+	viper.SetDefault("sync_config.ownership_marker", "[managed by gitea-ldap-sync]")
+	viper.SetDefault("sync_config.migrate_local_users", false)
+	viper.SetDefault("sync_config.protected.users", []string{"root"})
+	viper.SetDefault("sync_config.protected.users_regex", "")
+	viper.SetDefault("sync_config.protected.organizations", []string{})
+	viper.SetDefault("sync_config.protected.organizations_regex", "")
+	viper.SetDefault("sync_config.protected.teams", []string{})
+	viper.SetDefault("sync_config.protected.teams_regex", "")
+	viper.SetDefault("sync_config.protected.local_users", true)
 	viper.SetDefault("sync_config.defaults.user.allow_create_organization", false)
 	viper.SetDefault("sync_config.defaults.user.max_repo_creation", 0)
 	viper.SetDefault("sync_config.defaults.user.visibility", "private")
@@ -228,60 +549,125 @@ func setDefaults() {
 	)
 }
 
+// check reports every missing and invalid setting at once.
 func (c *Config) check() error {
-	var missing []string
+	problems := append(c.validate(), c.checkTargets()...)
+
+	if len(c.Profiles) == 0 {
+		problems = append(problems, withMissing(c.LDAP.missing(), c.validateProfile())...)
+	}
+
+	problems = append(problems, c.checkProfiles()...)
 
-	if c.Gitea.User == "" {
-		missing = append(missing, "GITEA_USER")
+	if len(problems) != 0 {
+		return errors.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
 	}
 
-	if c.Gitea.Token == "" {
-		missing = append(missing, "GITEA_TOKEN")
+	return nil
+}
+
+func withMissing(missing, problems []string) []string {
+	if len(missing) == 0 {
+		return problems
+	}
+
+	return append([]string{fmt.Sprintf("required attribute is missing: %s", strings.Join(missing, ", "))}, problems...)
+}
+
+func (c *GiteaConfig) missing() []string {
+	var missing []string
+
+	switch c.GetAuthMethod() {
+	case AuthMethodBasic:
+		if c.User == "" {
+			missing = append(missing, "GITEA_USER")
+		}
+
+		if c.Password == "" && c.PasswordFile == "" {
+			missing = append(missing, "GITEA_PASSWORD")
+		}
+	default:
+		if c.Token == "" && c.TokenFile == "" {
+			missing = append(missing, "GITEA_TOKEN")
+		}
 	}
 
-	if c.Gitea.BaseURL == "" {
+	if c.BaseURL == "" {
 		missing = append(missing, "GITEA_BASE_URL")
 	}
 
-	if c.Gitea.AuthSourceID == 0 {
+	if c.AuthSourceID == 0 {
 		missing = append(missing, "GITEA_AUTH_SOURCE_ID")
 	}
 
-	if c.LDAP.URL == "" {
+	return missing
+}
+
+func (c *LDAPConfig) missing() []string {
+	var missing []string
+
+	if c.URL == "" && c.SRVDomain == "" {
 		missing = append(missing, "LDAP_URL")
 	}
 
-	if c.LDAP.BindDN == "" && c.LDAP.BindPassword == "" {
-		missing = append(missing, "LDAP_BIND_DN", "LDAP_BIND_PASSWORD")
-	}
+	missing = append(missing, c.missingBindSettings()...)
 
-	if c.LDAP.UserFilter == "" {
+	if c.UserFilter == "" {
 		missing = append(missing, "LDAP_USER_FILTER")
 	}
 
-	if c.LDAP.UserSearchBase == "" {
+	if c.UserSearchBase == "" {
 		missing = append(missing, "LDAP_USER_SEARCH_BASE")
 	}
 
-	if c.LDAP.GroupFilter == "" {
+	if c.GroupFilter == "" {
 		missing = append(missing, "LDAP_GROUP_FILTER")
 	}
 
-	if c.LDAP.GroupSearchBase == "" {
+	if c.GroupSearchBase == "" {
 		missing = append(missing, "LDAP_GROUP_SEARCH_BASE")
 	}
 
-	if c.LDAP.SubgroupFilter == "" {
+	if c.SubgroupFilter == "" {
 		missing = append(missing, "LDAP_SUBGROUP_FILTER")
 	}
 
-	if c.LDAP.SubgroupSearchBase == "" {
+	if c.SubgroupSearchBase == "" {
 		missing = append(missing, "LDAP_SUBGROUP_SEARCH_BASE")
 	}
 
-	if len(missing) != 0 {
-		return errors.Errorf("required attribute is missing: %s", strings.Join(missing, ", "))
+	return missing
+}
+
+func (c *LDAPConfig) missingBindSettings() []string {
+	var missing []string
+
+	switch c.GetBindMethod() {
+	case BindMethodExternal:
+		if c.ClientCertFile == "" {
+			missing = append(missing, "LDAP_CLIENT_CERT_FILE")
+		}
+
+		if c.ClientKeyFile == "" {
+			missing = append(missing, "LDAP_CLIENT_KEY_FILE")
+		}
+	case BindMethodGSSAPI:
+		if c.KerberosUsername == "" {
+			missing = append(missing, "LDAP_KERBEROS_USERNAME")
+		}
+
+		if c.KerberosRealm == "" {
+			missing = append(missing, "LDAP_KERBEROS_REALM")
+		}
+
+		if c.KerberosKeytabFile == "" {
+			missing = append(missing, "LDAP_KERBEROS_KEYTAB_FILE")
+		}
+	default:
+		if c.BindDN == "" && c.BindPassword == "" && c.BindPasswordFile == "" {
+			missing = append(missing, "LDAP_BIND_DN", "LDAP_BIND_PASSWORD")
+		}
 	}
 
-	return nil
+	return missing
 }
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Sources of a setting.
+const (
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+const redacted = "******"
+
+// Setting is a single effective configuration value and its source.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+//nolint:gochecknoglobals
+var (
+	secretKeys = []string{"gitea.token", "gitea.password", "ldap.bind_password", "vault.token"}
+
+	// envAliases lists the environment variables which are bound in addition to the default name of the key.
+	envAliases = map[string][]string{
+		"vault.address": {"VAULT_ADDRESS", "VAULT_ADDR"},
+	}
+)
+
+// Effective returns the merged configuration loaded by Load with secrets redacted, sorted by key.
+func Effective() []Setting {
+	keys := viper.AllKeys()
+	sort.Strings(keys)
+
+	settings := make([]Setting, 0, len(keys))
+
+	for _, key := range keys {
+		if key == "profiles" || key == "targets" {
+			settings = append(settings, listSettings(key)...)
+
+			continue
+		}
+
+		settings = append(settings, Setting{
+			Key:    key,
+			Value:  redact(key, fmt.Sprint(viper.Get(key))),
+			Source: source(key),
+		})
+	}
+
+	return settings
+}
+
+// listSettings returns the settings of the profiles or the targets as set in the config file (without the inherited
+// top-level settings), eg.: profiles.0.ldap.user_filter.
+func listSettings(list string) []Setting {
+	raw, _ := viper.Get(list).([]interface{})
+
+	values := map[string]interface{}{}
+	for i, p := range raw {
+		flatten(fmt.Sprintf("%s.%d", list, i), p, values)
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	settings := make([]Setting, 0, len(keys))
+
+	for _, key := range keys {
+		// The key without the <list>.<index> prefix.
+		parts := strings.SplitN(key, ".", 3) //nolint:mnd
+		setting := parts[len(parts)-1]
+
+		settings = append(settings, Setting{
+			Key:    key,
+			Value:  redact(setting, fmt.Sprint(values[key])),
+			Source: SourceFile,
+		})
+	}
+
+	return settings
+}
+
+func flatten(prefix string, value interface{}, out map[string]interface{}) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		out[prefix] = value
+
+		return
+	}
+
+	for k, v := range m {
+		flatten(prefix+"."+k, v, out)
+	}
+}
+
+func source(key string) string {
+	names := envAliases[key]
+	if len(names) == 0 {
+		names = []string{strings.ToUpper(strings.ReplaceAll(key, ".", "_"))}
+	}
+
+	for _, name := range names {
+		if _, ok := os.LookupEnv(name); ok {
+			return SourceEnv
+		}
+	}
+
+	if viper.InConfig(key) {
+		return SourceFile
+	}
+
+	return SourceDefault
+}
+
+// redact hides the value of secrets. References to secret providers (eg.: vault:path#key) are not secret.
+func redact(key, value string) string {
+	if value == "" || strings.HasPrefix(value, "file:") || strings.HasPrefix(value, "vault:") {
+		return value
+	}
+
+	for _, k := range secretKeys {
+		if key == k {
+			return redacted
+		}
+	}
+
+	if strings.HasPrefix(key, "gitea.headers.") {
+		return redacted
+	}
+
+	return value
+}
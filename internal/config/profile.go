@@ -0,0 +1,220 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// DefaultProfile is the name of the profile built from the top-level settings if no profiles are configured.
+const DefaultProfile = "default"
+
+// Profile is a separate LDAP to Gitea mapping with its own LDAP searches, mapping rules, defaults and schedule. The
+// settings of a profile override the top-level settings.
+type Profile struct {
+	Name       string      `mapstructure:"name"`
+	CronTimer  string      `mapstructure:"cron_timer"`
+	LDAP       *LDAPConfig `mapstructure:"ldap"`
+	SyncConfig *SyncConfig `mapstructure:"sync_config"`
+
+	// Targets are the Gitea targets with the sync_config settings of the profile.
+	Targets []*Target `mapstructure:"-"`
+}
+
+// GetProfiles returns the configured profiles. If there are none, a single default profile is returned which uses
+// the top-level settings.
+func (c *Config) GetProfiles() []*Profile {
+	if len(c.Profiles) != 0 {
+		return c.Profiles
+	}
+
+	return []*Profile{{
+		Name:       DefaultProfile,
+		CronTimer:  c.CronTimer,
+		LDAP:       c.LDAP,
+		SyncConfig: c.SyncConfig,
+		Targets:    c.Targets,
+	}}
+}
+
+// GetProfile returns the profile with the given name or nil if it does not exist.
+func (c *Config) GetProfile(name string) *Profile {
+	for _, p := range c.GetProfiles() {
+		if p.Name == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// ForProfile returns a copy of the configuration which uses the settings of the profile.
+func (c *Config) ForProfile(p *Profile) *Config {
+	cfg := *c
+	cfg.ProfileName = p.Name
+	cfg.CronTimer = p.CronTimer
+	cfg.LDAP = p.LDAP
+	cfg.SyncConfig = p.SyncConfig
+	cfg.Targets = p.Targets
+	cfg.Profiles = nil
+
+	return &cfg
+}
+
+func (c *Config) checkProfiles() []string {
+	var problems []string
+
+	names := make(map[string]struct{}, len(c.Profiles))
+
+	for i, p := range c.Profiles {
+		if p.Name == "" {
+			problems = append(problems, fmt.Sprintf("profiles[%d].name: required attribute is missing", i))
+
+			continue
+		}
+
+		if _, ok := names[p.Name]; ok {
+			problems = append(problems, fmt.Sprintf("profiles[%d].name: duplicate profile: %s", i, p.Name))
+		}
+
+		names[p.Name] = struct{}{}
+
+		pc := c.ForProfile(p)
+
+		for _, problem := range withMissing(pc.LDAP.missing(), pc.validateProfile()) {
+			problems = append(problems, fmt.Sprintf("profiles[%s]: %s", p.Name, problem))
+		}
+
+		for _, t := range pc.GetTargets() {
+			for _, problem := range t.SyncConfig.problems() {
+				problems = append(problems, fmt.Sprintf("profiles[%s]: targets[%s]: %s", p.Name, t.Name, problem))
+			}
+		}
+	}
+
+	return problems
+}
+
+// loadProfiles builds the profiles and the Gitea targets by merging their settings over the top-level settings. The
+// targets of a profile inherit the sync_config settings of the profile.
+func loadProfiles(cfg *Config) error {
+	settings := viper.AllSettings()
+
+	targets, err := loadTargets(map[string]interface{}{
+		"gitea":       settings["gitea"],
+		"sync_config": settings["sync_config"],
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.Targets = targets
+
+	raw, err := overridesList("profiles")
+	if err != nil {
+		return err
+	}
+
+	base := map[string]interface{}{
+		"cron_timer":  settings["cron_timer"],
+		"ldap":        settings["ldap"],
+		"sync_config": settings["sync_config"],
+	}
+
+	profiles := make([]*Profile, 0, len(raw))
+
+	for i, overrides := range raw {
+		merged := mergeMaps(base, overrides)
+		clearInheritedSecretFiles(merged, overrides)
+
+		p := &Profile{}
+		if err := decode(merged, p); err != nil {
+			return errors.Wrapf(err, "decoding profiles[%d]", i)
+		}
+
+		if p.Targets, err = loadTargets(map[string]interface{}{
+			"gitea":       settings["gitea"],
+			"sync_config": merged["sync_config"],
+		}); err != nil {
+			return err
+		}
+
+		profiles = append(profiles, p)
+	}
+
+	cfg.Profiles = profiles
+
+	return nil
+}
+
+func decode(input, output interface{}) error {
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       decodeHook(),
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		Result:           output,
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.Decode(input)
+}
+
+// secretFiles are the secrets which can be read from a file by their section: the setting of the value and the
+// setting of the file.
+//
+//nolint:gochecknoglobals
+var secretFiles = map[string]map[string]string{
+	"gitea": {"token": "token_file", "password": "password_file"},
+	"ldap":  {"bind_password": "bind_password_file"},
+}
+
+// clearInheritedSecretFiles clears the inherited secret files whose value is overridden, otherwise the inherited file
+// would take precedence over the value of the override (eg.: the token of a target).
+func clearInheritedSecretFiles(merged, overrides map[string]interface{}) {
+	for section, files := range secretFiles {
+		o, _ := overrides[section].(map[string]interface{})
+		m, _ := merged[section].(map[string]interface{})
+
+		for value, file := range files {
+			if _, ok := o[value]; !ok {
+				continue
+			}
+
+			if _, ok := o[file]; ok {
+				continue
+			}
+
+			if _, ok := m[file]; ok {
+				m[file] = ""
+			}
+		}
+	}
+}
+
+// mergeMaps returns a new map with the values of src merged over dst recursively.
+func mergeMaps(dst, src map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(dst)+len(src))
+
+	for k, v := range dst {
+		merged[k] = v
+	}
+
+	for k, v := range src {
+		srcMap, srcOK := v.(map[string]interface{})
+		dstMap, dstOK := merged[k].(map[string]interface{})
+
+		if srcOK && dstOK {
+			merged[k] = mergeMaps(dstMap, srcMap)
+
+			continue
+		}
+
+		merged[k] = v
+	}
+
+	return merged
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// Schema returns the JSON Schema of the config file. It is generated from the Config structure, so it always matches
+// the keys accepted by Load.
+func Schema() ([]byte, error) {
+	s := schemaOf(reflect.TypeOf(Config{}), "")
+	s["$schema"] = schemaDraft
+	s["title"] = "gitea-ldap-sync configuration"
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// schemaEnums returns the valid values of the keys which accept only a fixed set of values.
+func schemaEnums() map[string][]string {
+	enums := map[string][]string{
+		"gitea.auth_method":                            {AuthMethodToken, AuthMethodBasic},
+		"ldap.tls_mode":                                {"", TLSModeNone, TLSModeLDAPS, TLSModeStartTLS},
+		"ldap.tls_min_version":                         {"", "1.0", "1.1", "1.2", "1.3"},
+		"ldap.bind_method":                             {BindMethodSimple, BindMethodExternal, BindMethodGSSAPI},
+		"sync_config.defaults.user.visibility":         visibilities,
+		"sync_config.defaults.organization.visibility": visibilities,
+	}
+
+	for _, p := range permissions {
+		enums["sync_config.defaults.team.permission"] = append(enums["sync_config.defaults.team.permission"], string(p))
+	}
+
+	for _, u := range units {
+		enums["sync_config.defaults.team.units"] = append(enums["sync_config.defaults.team.units"], string(u))
+	}
+
+	return enums
+}
+
+// schemaEnum returns the valid values of the key. The settings of the profiles and the targets have the same valid
+// values as the top-level settings.
+func schemaEnum(key string) ([]string, bool) {
+	key = strings.TrimPrefix(key, "profiles.")
+	key = strings.TrimPrefix(key, "targets.")
+
+	enum, ok := schemaEnums()[key]
+
+	return enum, ok
+}
+
+//nolint:exhaustive
+func schemaOf(t reflect.Type, key string) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]interface{}{
+			"type":        "string",
+			"pattern":     `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`,
+			"description": "Duration, eg.: 30s, 5m, 1h30m.",
+		}
+	}
+
+	s := map[string]interface{}{}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]interface{}{}
+
+		for i := range t.NumField() {
+			f := t.Field(i)
+
+			name := f.Tag.Get("mapstructure")
+			if name == "" || name == "-" {
+				continue
+			}
+
+			child := name
+			if key != "" {
+				child = key + "." + name
+			}
+
+			props[name] = schemaOf(f.Type, child)
+		}
+
+		s["type"] = "object"
+		s["properties"] = props
+		s["additionalProperties"] = false
+	case reflect.Map:
+		s["type"] = "object"
+		s["additionalProperties"] = schemaOf(t.Elem(), "")
+	case reflect.Slice:
+		if elem := t.Elem(); elem.Kind() == reflect.Ptr && elem.Elem().Kind() == reflect.Struct {
+			s["type"] = "array"
+			s["items"] = schemaOf(elem, key)
+
+			break
+		}
+
+		// Lists can also be set as a comma separated string, like in the environment variables.
+		s["type"] = []string{"array", "string"}
+		s["items"] = schemaOf(t.Elem(), "")
+
+		if enum, ok := schemaEnum(key); ok {
+			s["items"] = map[string]interface{}{"type": "string", "enum": enum}
+		}
+	case reflect.Bool:
+		s["type"] = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s["type"] = "integer"
+	default:
+		s["type"] = "string"
+	}
+
+	if enum, ok := schemaEnum(key); ok && t.Kind() != reflect.Slice {
+		s["enum"] = slices.Clone(enum)
+	}
+
+	return s
+}
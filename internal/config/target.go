@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// DefaultTarget is the name of the Gitea target built from the top-level settings if no targets are configured.
+const DefaultTarget = "default"
+
+// Target is a Gitea instance synced from the same LDAP directory, with its own credentials, auth source and
+// defaults. The settings of a target override the top-level gitea and sync_config settings.
+type Target struct {
+	Name       string       `mapstructure:"name"`
+	Gitea      *GiteaConfig `mapstructure:"gitea"`
+	SyncConfig *SyncConfig  `mapstructure:"sync_config"`
+}
+
+// GetTargets returns the configured Gitea targets. If there are none, a single default target is returned which uses
+// the top-level settings.
+func (c *Config) GetTargets() []*Target {
+	if len(c.Targets) != 0 {
+		return c.Targets
+	}
+
+	return []*Target{{Name: DefaultTarget, Gitea: c.Gitea, SyncConfig: c.SyncConfig}}
+}
+
+// ForTarget returns a copy of the configuration which uses the settings of the Gitea target.
+func (c *Config) ForTarget(t *Target) *Config {
+	cfg := *c
+	cfg.TargetName = t.Name
+	cfg.Gitea = t.Gitea
+	cfg.SyncConfig = t.SyncConfig
+	cfg.Targets = nil
+
+	return &cfg
+}
+
+func (c *Config) checkTargets() []string {
+	if len(c.Targets) == 0 {
+		return withMissing(c.Gitea.missing(), c.validateTarget())
+	}
+
+	var problems []string
+
+	names := make(map[string]struct{}, len(c.Targets))
+
+	for i, t := range c.Targets {
+		if t.Name == "" {
+			problems = append(problems, fmt.Sprintf("targets[%d].name: required attribute is missing", i))
+
+			continue
+		}
+
+		if _, ok := names[t.Name]; ok {
+			problems = append(problems, fmt.Sprintf("targets[%d].name: duplicate target: %s", i, t.Name))
+		}
+
+		names[t.Name] = struct{}{}
+
+		tc := c.ForTarget(t)
+
+		for _, problem := range withMissing(tc.Gitea.missing(), tc.validateTarget()) {
+			problems = append(problems, fmt.Sprintf("targets[%s]: %s", t.Name, problem))
+		}
+	}
+
+	return problems
+}
+
+// loadTargets builds the targets by merging the settings of every target over the given gitea and sync_config
+// settings (the top-level settings or the settings of a profile).
+func loadTargets(base map[string]interface{}) ([]*Target, error) {
+	raw, err := overridesList("targets")
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]*Target, 0, len(raw))
+
+	for i, overrides := range raw {
+		merged := mergeMaps(base, overrides)
+		clearInheritedSecretFiles(merged, overrides)
+
+		t := &Target{}
+		if err := decode(merged, t); err != nil {
+			return nil, errors.Wrapf(err, "decoding targets[%d]", i)
+		}
+
+		targets = append(targets, t)
+	}
+
+	return targets, nil
+}
+
+// overridesList returns the items of a list of overrides (eg.: profiles or targets) from the config file.
+func overridesList(key string) ([]map[string]interface{}, error) {
+	raw, _ := viper.Get(key).([]interface{})
+
+	list := make([]map[string]interface{}, 0, len(raw))
+
+	for i, r := range raw {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("decoding %s[%d]: must be a map", key, i)
+		}
+
+		list = append(list, m)
+	}
+
+	return list, nil
+}
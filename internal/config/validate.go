@@ -0,0 +1,300 @@
+package config
+
+import (
+	"fmt"
+	urlpkg "net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/naming"
+)
+
+const (
+	maxPort                  = 65535
+	maxOwnershipMarkerLength = 64
+)
+
+//nolint:gochecknoglobals
+var (
+	visibilities = []string{
+		string(gitea.VisibleTypePublic), string(gitea.VisibleTypeLimited), string(gitea.VisibleTypePrivate),
+	}
+	permissions = []gitea.AccessMode{
+		gitea.AccessModeNone, gitea.AccessModeRead, gitea.AccessModeWrite, gitea.AccessModeAdmin,
+	}
+	units = []gitea.RepoUnitType{
+		gitea.RepoUnitCode, gitea.RepoUnitIssues, gitea.RepoUnitPulls, gitea.RepoUnitExtIssues, gitea.RepoUnitWiki,
+		gitea.RepoUnitExtWiki, gitea.RepoUnitReleases, gitea.RepoUnitProjects, gitea.RepoUnitPackages,
+		gitea.RepoUnitActions,
+	}
+	collaboratorPermissions = []gitea.AccessMode{gitea.AccessModeRead, gitea.AccessModeWrite, gitea.AccessModeAdmin}
+)
+
+// validation collects the problems of the configuration, so all of them can be reported at once.
+type validation struct {
+	problems []string
+}
+
+func (v *validation) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validation) regex(key, expr string) {
+	if expr == "" {
+		return
+	}
+
+	if _, err := regexp.Compile(expr); err != nil {
+		v.addf("%s: invalid regular expression: %s", key, err)
+	}
+}
+
+func (v *validation) glob(key, pattern string) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		v.addf("%s: invalid pattern: %q", key, pattern)
+	}
+}
+
+func (v *validation) template(key, text string) {
+	if _, err := naming.Parse(key, text); err != nil {
+		v.addf("%s: invalid template: %s", key, errors.Cause(err))
+	}
+}
+
+func (v *validation) filter(key, filter string) {
+	if filter == "" {
+		return
+	}
+
+	if _, err := ldap.CompileFilter(filter); err != nil {
+		v.addf("%s: invalid ldap filter: %s", key, err)
+	}
+}
+
+func (v *validation) oneOf(key, value string, valid []string) {
+	if !slices.Contains(valid, value) {
+		v.addf("%s: invalid value: %q (valid values: %s)", key, value, strings.Join(valid, ", "))
+	}
+}
+
+func (v *validation) url(key, value string, schemes ...string) {
+	if value == "" {
+		return
+	}
+
+	// The url may contain credentials, so only the cause of the error and the redacted url are reported.
+	u, err := urlpkg.Parse(value)
+	if err != nil {
+		var urlErr *urlpkg.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		v.addf("%s: invalid url: %s", key, err)
+
+		return
+	}
+
+	if !slices.Contains(schemes, strings.ToLower(u.Scheme)) || u.Host == "" {
+		v.addf("%s: invalid url: %q (expected %s://<host>)", key, u.Redacted(), strings.Join(schemes, "|"))
+	}
+}
+
+func (v *validation) positive(key string, value int64) {
+	if value <= 0 {
+		v.addf("%s: must be greater than zero", key)
+	}
+}
+
+// validate checks the format and the allowed values of the settings shared by the profiles and the targets.
+func (c *Config) validate() []string {
+	v := &validation{}
+
+	v.positive("run_timeout", int64(c.RunTimeout))
+	v.positive("retry.max_attempts", int64(c.Retry.MaxAttempts))
+
+	if c.Vault.Address != "" {
+		v.url("vault.address", c.Vault.Address, "http", "https")
+		v.oneOf("vault.kv_version", fmt.Sprint(c.Vault.KVVersion), []string{"1", "2"})
+	}
+
+	return v.problems
+}
+
+// validateProfile checks the format and the allowed values of the settings which can be set per profile.
+func (c *Config) validateProfile() []string {
+	v := &validation{}
+
+	if c.CronEnabled {
+		if _, err := cron.ParseStandard(c.CronTimer); err != nil {
+			v.addf("cron_timer: invalid schedule: %s", err)
+		}
+	}
+
+	c.LDAP.validate(v)
+
+	if c.LDAP.UserIDAttribute != "" && c.StateFile == "" {
+		v.addf("state_file: must be set if ldap.user_id_attribute is set, the user names are remembered in it")
+	}
+
+	return v.problems
+}
+
+// validateTarget checks the format and the allowed values of the settings which can be set per Gitea target.
+func (c *Config) validateTarget() []string {
+	v := &validation{}
+
+	c.Gitea.validate(v)
+	c.SyncConfig.validate(v)
+
+	return v.problems
+}
+
+func (c *GiteaConfig) validate(v *validation) {
+	v.url("gitea.base_url", c.BaseURL, "http", "https")
+	v.url("gitea.proxy_url", c.ProxyURL, "http", "https", "socks5")
+	v.oneOf("gitea.auth_method", c.GetAuthMethod(), []string{AuthMethodToken, AuthMethodBasic})
+	v.positive("gitea.client_timeout", int64(c.ClientTimeout))
+}
+
+func (c *LDAPConfig) validate(v *validation) {
+	v.oneOf("ldap.tls_mode", c.GetTLSMode(), []string{TLSModeNone, TLSModeLDAPS, TLSModeStartTLS})
+	v.oneOf("ldap.tls_min_version", c.TLSMinVersion, []string{"", "1.0", "1.1", "1.2", "1.3"})
+	v.oneOf("ldap.bind_method", c.GetBindMethod(), []string{BindMethodSimple, BindMethodExternal, BindMethodGSSAPI})
+	v.positive("ldap.connect_timeout", int64(c.ConnectTimeout))
+	v.positive("ldap.operation_timeout", int64(c.OperationTimeout))
+
+	for _, u := range strings.FieldsFunc(c.URL, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if strings.Contains(u, "://") {
+			v.url("ldap.url", u, "ldap", "ldaps")
+		} else if c.Port <= 0 || c.Port > maxPort {
+			v.addf("ldap.port: invalid port: %d", c.Port)
+		}
+	}
+
+	v.filter("ldap.user_filter", c.UserFilter)
+	v.filter("ldap.admin_filter", c.AdminFilter)
+	v.filter("ldap.restricted_filter", c.RestrictedFilter)
+	v.filter("ldap.group_filter", c.GroupFilter)
+	v.filter("ldap.subgroup_filter", c.SubgroupFilter)
+
+	v.regex("ldap.exclude_users_regex", c.ExcludeUsersRegex)
+	v.regex("ldap.exclude_groups_regex", c.ExcludeGroupsRegex)
+	v.regex("ldap.exclude_subgroups_regex", c.ExcludeSubgroupsRegex)
+
+	v.template("ldap.group_name_template", c.GroupNameTemplate)
+	v.template("ldap.group_fullname_template", c.GroupFullNameTemplate)
+	v.template("ldap.group_description_template", c.GroupDescriptionTemplate)
+	v.template("ldap.subgroup_name_template", c.SubgroupNameTemplate)
+	v.template("ldap.subgroup_description_template", c.SubgroupDescriptionTemplate)
+
+	if c.DefaultTeam != "" {
+		switch {
+		case !naming.IsValidTeamName(c.DefaultTeam):
+			v.addf("ldap.default_team: invalid team name: %q", c.DefaultTeam)
+		case strings.EqualFold(c.DefaultTeam, "owners"):
+			v.addf("ldap.default_team: the Owners team can't be the default team")
+		}
+	}
+
+	if c.GetBindMethod() == BindMethodExternal && c.plaintext() {
+		v.addf("ldap.bind_method: external requires TLS (tls_mode: ldaps or starttls), the client certificate is " +
+			"sent in the TLS handshake")
+	}
+
+	if c.AllowInsecureTLS && (c.CAFile != "" || c.ServerName != "") {
+		v.addf("ldap.allow_insecure_tls: must be disabled if ldap.ca_file or ldap.server_name is set, the server " +
+			"certificate is not verified otherwise")
+	}
+
+	if c.TrimParentName && c.SubgroupSeparator == "" {
+		v.addf("ldap.subgroup_separator: must be set if ldap.trim_parent_name is enabled")
+	}
+}
+
+// plaintext reports whether any of the LDAP servers is connected to without TLS.
+func (c *LDAPConfig) plaintext() bool {
+	mode := c.GetTLSMode()
+	if mode == TLSModeNone {
+		return true
+	}
+
+	for _, u := range strings.FieldsFunc(c.URL, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if strings.HasPrefix(strings.ToLower(u), "ldap://") && mode != TLSModeStartTLS {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *SyncConfig) problems() []string {
+	v := &validation{}
+	c.validate(v)
+
+	return v.problems
+}
+
+func (c *SyncConfig) validate(v *validation) {
+	if len(c.OwnershipMarker) > maxOwnershipMarkerLength {
+		v.addf("sync_config.ownership_marker: too long (max %d characters)", maxOwnershipMarkerLength)
+	}
+
+	for i, tr := range c.TeamRepositories {
+		tr.validate(v, fmt.Sprintf("sync_config.team_repositories.%d", i))
+	}
+
+	for i, cr := range c.Collaborators {
+		cr.validate(v, fmt.Sprintf("sync_config.collaborators.%d", i))
+	}
+
+	v.regex("sync_config.protected.users_regex", c.Protected.UsersRegex)
+	v.regex("sync_config.protected.organizations_regex", c.Protected.OrganizationsRegex)
+	v.regex("sync_config.protected.teams_regex", c.Protected.TeamsRegex)
+
+	v.oneOf("sync_config.defaults.user.visibility", c.Defaults.User.Visibility, visibilities)
+	v.oneOf("sync_config.defaults.organization.visibility", c.Defaults.Organization.Visibility, visibilities)
+
+	if !slices.Contains(permissions, c.Defaults.Team.Permission) {
+		v.addf("sync_config.defaults.team.permission: invalid value: %q", c.Defaults.Team.Permission)
+	}
+
+	for _, u := range c.Defaults.Team.Units {
+		if !slices.Contains(units, u) {
+			v.addf("sync_config.defaults.team.units: invalid unit: %q", u)
+		}
+	}
+}
+
+func (c *TeamRepositories) validate(v *validation, key string) {
+	if c.Team == "" {
+		v.addf("%s.team: must be set", key)
+	}
+
+	v.glob(key+".team", c.Team)
+
+	for _, repo := range c.Repositories {
+		v.glob(key+".repositories", repo)
+	}
+}
+
+func (c *Collaborators) validate(v *validation, key string) {
+	if c.Group == "" {
+		v.addf("%s.group: must be set", key)
+	}
+
+	if owner, repo, ok := strings.Cut(c.Repository, "/"); !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		v.addf("%s.repository: invalid repository: %q (expected <owner>/<repository>)", key, c.Repository)
+	}
+
+	if !slices.Contains(collaboratorPermissions, c.Permission) {
+		v.addf("%s.permission: invalid value: %q (valid values: read, write, admin)", key, c.Permission)
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// watchDebounce groups the events of a single save (editors and Kubernetes write the file in multiple steps).
+const watchDebounce = time.Second
+
+// FileUsed returns the path of the config file read by the last Load. It's empty if no config file was found.
+func FileUsed() string {
+	return viper.ConfigFileUsed()
+}
+
+// Watch calls onChange when the config file changes until ctx is done. The directory of the file is watched, so
+// replacing the file or the symlink pointing to it (eg.: Kubernetes ConfigMap updates) is detected too.
+func Watch(ctx context.Context, path string, onChange func()) error {
+	log := log.Logger.With().Str("tag", "[config]").Logger()
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrapf(err, "resolving config file path: %s", path)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "creating config file watcher")
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return errors.Wrapf(err, "watching config file: %s", path)
+	}
+
+	realPath, _ := filepath.EvalSymlinks(path)
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			current, _ := filepath.EvalSymlinks(path)
+
+			written := filepath.Clean(event.Name) == path && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+			if !written && (current == "" || current == realPath) {
+				continue
+			}
+
+			realPath = current
+
+			debounce.Reset(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			log.Warn().Err(err).Msg("Config file watcher error")
+		case <-debounce.C:
+			onChange()
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+)
+
+func TestWatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, dir, path string)
+	}{
+		{
+			name: "Test if writing the config file is detected",
+			change: func(t *testing.T, _, path string) {
+				t.Helper()
+
+				if err := os.WriteFile(path, []byte("cron_enabled: false\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "Test if replacing the config file is detected",
+			change: func(t *testing.T, dir, path string) {
+				t.Helper()
+
+				tmp := filepath.Join(dir, "config.yaml.tmp")
+				if err := os.WriteFile(tmp, []byte("cron_enabled: false\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := os.Rename(tmp, path); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "config.yaml")
+
+			if err := os.WriteFile(path, []byte("cron_enabled: true\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			changed := make(chan struct{}, 1)
+			done := make(chan error, 1)
+
+			go func() {
+				done <- config.Watch(ctx, path, func() {
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				})
+			}()
+
+			// Give the watcher time to start.
+			time.Sleep(100 * time.Millisecond)
+
+			tt.change(t, dir, path)
+
+			select {
+			case <-changed:
+			case err := <-done:
+				t.Fatalf("Watch() returned early: %v", err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("config file change was not detected")
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+)
+
+// Server flavors.
+const (
+	FlavorGitea   = "gitea"
+	FlavorForgejo = "forgejo"
+)
+
+//nolint:gochecknoglobals
+var (
+	// minVersion is the oldest Gitea API level supported by the sync.
+	minVersion = version.Must(version.NewVersion("1.13.0"))
+
+	// userVisibilityVersion is the first Gitea version supporting the user visibility.
+	userVisibilityVersion = version.Must(version.NewVersion("1.15.0"))
+
+	// userSourceVersion is the first Gitea version reporting the authentication source of the users.
+	userSourceVersion = version.Must(version.NewVersion("1.18.0"))
+
+	// userRenameVersion is the first Gitea version providing the API to rename the users.
+	userRenameVersion = version.Must(version.NewVersion("1.20.0"))
+
+	// unitVersions are the first Gitea versions supporting the repository units introduced after minVersion.
+	unitVersions = map[gitea.RepoUnitType]*version.Version{
+		gitea.RepoUnitPackages: version.Must(version.NewVersion("1.17.0")),
+		gitea.RepoUnitActions:  version.Must(version.NewVersion("1.19.0")),
+	}
+)
+
+// Capabilities describes the server and the features it supports.
+type Capabilities struct {
+	Flavor string
+	// Version is the version reported by the server.
+	Version string
+	// APIVersion is the Gitea version the API of the server is compatible with. For Gitea it's the same as Version,
+	// Forgejo reports it in the build metadata of its version, eg.: 9.0.0+gitea-1.22.0.
+	APIVersion *version.Version
+}
+
+func (c *Capabilities) String() string {
+	if c.Flavor == FlavorGitea {
+		return fmt.Sprintf("%s %s", c.Flavor, c.Version)
+	}
+
+	return fmt.Sprintf("%s %s (gitea api %s)", c.Flavor, c.Version, c.APIVersion)
+}
+
+// UserVisibility reports whether the visibility of the users can be set.
+func (c *Capabilities) UserVisibility() bool {
+	return c.APIVersion.GreaterThanOrEqual(userVisibilityVersion)
+}
+
+// UserSource reports whether the authentication source of the users is reported by the server.
+func (c *Capabilities) UserSource() bool {
+	return c.APIVersion.GreaterThanOrEqual(userSourceVersion)
+}
+
+// UserRename reports whether the users can be renamed.
+func (c *Capabilities) UserRename() bool {
+	return c.APIVersion.GreaterThanOrEqual(userRenameVersion)
+}
+
+// Unit reports whether the repository unit is supported.
+func (c *Capabilities) Unit(u gitea.RepoUnitType) bool {
+	v, ok := unitVersions[u]
+
+	return !ok || c.APIVersion.GreaterThanOrEqual(v)
+}
+
+// ParseServerVersion detects the flavor and the API level of the server from its reported version. Forgejo is
+// detected from its version or from forgejoAPI, which reports whether the Forgejo specific API is available.
+func ParseServerVersion(raw string, forgejoAPI bool) (*Capabilities, error) {
+	caps := &Capabilities{Flavor: FlavorGitea, Version: raw}
+
+	apiVersion := raw
+	if i := strings.Index(raw, "+gitea-"); i >= 0 {
+		caps.Flavor = FlavorForgejo
+		apiVersion = raw[i+len("+gitea-"):]
+	}
+
+	if forgejoAPI {
+		caps.Flavor = FlavorForgejo
+	}
+
+	v, err := version.NewVersion(apiVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing server version: %s", raw)
+	}
+
+	// Pre-releases and development builds (eg.: 1.22.0+dev-123) support the features of the release.
+	caps.APIVersion = version.Must(version.NewVersion(v.Core().String()))
+
+	if caps.APIVersion.LessThan(minVersion) {
+		return nil, errors.Errorf(
+			"server version is not supported: %s (at least gitea %s or a compatible forgejo is required)",
+			caps, minVersion,
+		)
+	}
+
+	return caps, nil
+}
+
+// detectCapabilities queries the version of the server.
+func (c *Client) detectCapabilities(ctx context.Context, httpClient *http.Client, baseURL string) error {
+	var raw string
+
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		raw, resp, err = c.client.ServerVersion()
+
+		return resp, err
+	}); err != nil {
+		return errors.Wrap(err, "getting the gitea server version")
+	}
+
+	caps, err := ParseServerVersion(raw, forgejoAPI(ctx, httpClient, baseURL))
+	if err != nil {
+		return err
+	}
+
+	c.caps = caps
+
+	return nil
+}
+
+// forgejoAPI reports whether the server provides the Forgejo specific API.
+func forgejoAPI(ctx context.Context, httpClient *http.Client, baseURL string) bool {
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/api/forgejo/v1/version", http.NoBody,
+	)
+	if err != nil {
+		return false
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
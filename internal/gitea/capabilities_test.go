@@ -0,0 +1,127 @@
+package gitea_test
+
+import (
+	"testing"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/gitea"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	tests := []struct {
+		name           string
+		version        string
+		forgejoAPI     bool
+		wantFlavor     string
+		wantAPIVersion string
+		wantVisibility bool
+		wantUserSource bool
+		wantUserRename bool
+		wantErr        bool
+	}{
+		{
+			name:           "Test if a gitea release is detected",
+			version:        "1.22.3",
+			wantFlavor:     gitea.FlavorGitea,
+			wantAPIVersion: "1.22.3",
+			wantVisibility: true,
+			wantUserSource: true,
+			wantUserRename: true,
+		},
+		{
+			name:           "Test if a gitea development build is detected",
+			version:        "1.23.0+dev-512-g1234567",
+			wantFlavor:     gitea.FlavorGitea,
+			wantAPIVersion: "1.23.0",
+			wantVisibility: true,
+			wantUserSource: true,
+			wantUserRename: true,
+		},
+		{
+			name:           "Test if forgejo is detected from the build metadata",
+			version:        "9.0.0+gitea-1.22.0",
+			wantFlavor:     gitea.FlavorForgejo,
+			wantAPIVersion: "1.22.0",
+			wantVisibility: true,
+			wantUserSource: true,
+			wantUserRename: true,
+		},
+		{
+			name:           "Test if forgejo is detected from the forgejo api",
+			version:        "1.21.11-1",
+			forgejoAPI:     true,
+			wantFlavor:     gitea.FlavorForgejo,
+			wantAPIVersion: "1.21.11",
+			wantVisibility: true,
+			wantUserSource: true,
+			wantUserRename: true,
+		},
+		{
+			name:           "Test if gitea 1.19 does not support renaming the users",
+			version:        "1.19.4",
+			wantFlavor:     gitea.FlavorGitea,
+			wantAPIVersion: "1.19.4",
+			wantVisibility: true,
+			wantUserSource: true,
+			wantUserRename: false,
+		},
+		{
+			name:           "Test if gitea 1.17 does not report the authentication source of the users",
+			version:        "1.17.4",
+			wantFlavor:     gitea.FlavorGitea,
+			wantAPIVersion: "1.17.4",
+			wantVisibility: true,
+			wantUserSource: false,
+		},
+		{
+			name:           "Test if an old gitea does not support the user visibility",
+			version:        "1.14.7",
+			wantFlavor:     gitea.FlavorGitea,
+			wantAPIVersion: "1.14.7",
+			wantVisibility: false,
+			wantUserSource: false,
+		},
+		{
+			name:    "Test if an unsupported gitea is rejected",
+			version: "1.12.6",
+			wantErr: true,
+		},
+		{
+			name:    "Test if an invalid version is rejected",
+			version: "unknown",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gitea.ParseServerVersion(tt.version, tt.forgejoAPI)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseServerVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.Flavor != tt.wantFlavor {
+				t.Errorf("Flavor = %s, want %s", got.Flavor, tt.wantFlavor)
+			}
+
+			if got.APIVersion.String() != tt.wantAPIVersion {
+				t.Errorf("APIVersion = %s, want %s", got.APIVersion, tt.wantAPIVersion)
+			}
+
+			if got.UserVisibility() != tt.wantVisibility {
+				t.Errorf("UserVisibility() = %v, want %v", got.UserVisibility(), tt.wantVisibility)
+			}
+
+			if got.UserSource() != tt.wantUserSource {
+				t.Errorf("UserSource() = %v, want %v", got.UserSource(), tt.wantUserSource)
+			}
+
+			if got.UserRename() != tt.wantUserRename {
+				t.Errorf("UserRename() = %v, want %v", got.UserRename(), tt.wantUserRename)
+			}
+		})
+	}
+}
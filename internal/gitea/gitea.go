@@ -1,8 +1,16 @@
 package gitea
 
 import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
 	urlpkg "net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"code.gitea.io/sdk/gitea"
 	"github.com/pkg/errors"
@@ -11,6 +19,7 @@ import (
 
 	"github.com/janosmiko/gitea-ldap-sync/internal/config"
 	"github.com/janosmiko/gitea-ldap-sync/internal/ptr"
+	"github.com/janosmiko/gitea-ldap-sync/internal/retry"
 )
 
 type (
@@ -19,6 +28,13 @@ type (
 	User         = gitea.User
 )
 
+var (
+	// ErrOtherAuthSource is returned if a user exists in Gitea, but it belongs to another authentication source.
+	ErrOtherAuthSource = errors.New("user belongs to another authentication source")
+	// ErrNotFound is returned if the requested object does not exist in Gitea.
+	ErrNotFound = errors.New("not found")
+)
+
 type Organizations []*Organization
 
 func (c Organizations) String() string {
@@ -35,6 +51,15 @@ type Client struct {
 	client *gitea.Client
 	config *config.Config
 	log    zerolog.Logger
+	me     *User
+	caps   *Capabilities
+
+	// httpClient and baseURL are used for the API calls not supported by the SDK.
+	httpClient *http.Client
+	baseURL    string
+
+	// mu serializes the SDK calls, as the SDK client only supports a single, client-wide context.
+	mu sync.Mutex
 }
 
 type Account struct {
@@ -42,6 +67,7 @@ type Account struct {
 	FullName string `json:"full_name"`
 	Login    string `json:"login"`
 	Email    string `json:"email"`
+	SourceID int64  `json:"source_id"`
 }
 
 func (c *Account) String() string {
@@ -67,39 +93,276 @@ type CreateTeamOpts struct {
 	Units                   []gitea.RepoUnitType
 }
 
-func New(conf *config.Config) (*Client, error) {
+func New(ctx context.Context, conf *config.Config) (*Client, error) {
+	// The url may contain credentials, so only the cause of the error is reported.
 	u, err := urlpkg.Parse(conf.Gitea.BaseURL)
 	if err != nil {
-		return nil, errors.Wrapf(err, "parsing gitea base url: %s", conf.Gitea.BaseURL)
+		var urlErr *urlpkg.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return nil, errors.Wrap(err, "parsing gitea base url")
+	}
+
+	l := log.Logger.With().Str("tag", "[gitea]").Logger()
+
+	// Credentials must not be part of the URL, as the URL may end up in logs and error messages.
+	if u.User != nil {
+		l.Warn().Msg("Credentials in the gitea base url are ignored, use the token or basic auth settings")
+
+		u.User = nil
+	}
+
+	httpClient, err := newHTTPClient(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := authOption(conf.Gitea)
+	if err != nil {
+		return nil, err
 	}
 
-	user := urlpkg.UserPassword(conf.Gitea.User, conf.Gitea.Token)
-	u.User = user
+	// The server version is detected by the client, so the SDK must not query it while initializing.
+	probe, err := gitea.NewClient(u.String(), gitea.SetGiteaVersion(""), gitea.SetHTTPClient(httpClient), auth)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating gitea client: %s", u.String())
+	}
+
+	c := &Client{
+		client:     probe,
+		config:     conf,
+		log:        l,
+		httpClient: httpClient,
+		baseURL:    strings.TrimSuffix(u.String(), "/"),
+	}
+
+	if err := c.detectCapabilities(ctx, httpClient, u.String()); err != nil {
+		return nil, err
+	}
 
-	client, err := gitea.NewClient(u.String())
+	// The SDK checks its features against the Gitea API level, which differs from the version of Forgejo.
+	client, err := gitea.NewClient(
+		u.String(), gitea.SetGiteaVersion(c.caps.APIVersion.String()), gitea.SetHTTPClient(httpClient), auth,
+	)
 	if err != nil {
-		return nil, errors.Wrapf(err, "creating gitea client for user: %s", u.User.Username())
+		return nil, errors.Wrapf(err, "creating gitea client: %s", u.String())
 	}
 
-	return &Client{
-		client: client,
-		config: conf,
-		log:    log.Logger.With().Str("tag", "[gitea]").Logger(),
-	}, nil
+	c.client = client
+
+	l.Info().Msgf("Connected to %s: %s", u.String(), c.caps)
+
+	c.checkCapabilities()
+
+	if err := c.checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if conf.Gitea.Sudo != "" {
+		client.SetSudo(conf.Gitea.Sudo)
+	}
+
+	return c, nil
+}
+
+func authOption(c *config.GiteaConfig) (gitea.ClientOption, error) {
+	switch c.GetAuthMethod() {
+	case config.AuthMethodToken:
+		return gitea.SetToken(c.Token), nil
+	case config.AuthMethodBasic:
+		return gitea.SetBasicAuth(c.User, c.Password), nil
+	default:
+		return nil, errors.Errorf("unknown gitea auth method: %s", c.AuthMethod)
+	}
+}
+
+// checkAdmin verifies that the credentials belong to a site administrator, as the sync uses the admin API.
+func (c *Client) checkAdmin(ctx context.Context) error {
+	var me *User
+
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		me, resp, err = c.client.GetMyUserInfo()
+
+		return resp, err
+	}); err != nil {
+		return errors.Wrap(err, "getting the current gitea user")
+	}
+
+	if !me.IsAdmin {
+		return errors.Errorf("gitea user is not a site administrator: %s", me.UserName)
+	}
+
+	if c.config.Gitea.User != "" && !strings.EqualFold(c.config.Gitea.User, me.UserName) {
+		c.log.Warn().Msgf("Configured gitea user (%s) does not match the token owner: %s", c.config.Gitea.User, me.UserName)
+	}
+
+	c.log.Debug().Msgf("Authenticated to gitea as: %s", me.UserName)
+	c.me = me
+
+	return nil
 }
 
-func (c *Client) AddUsersToTeam(users []Account, team int64) error {
+// checkCapabilities warns about the configured features which are not supported by the server. They are skipped.
+func (c *Client) checkCapabilities() {
+	if c.config.SyncConfig.Defaults.User.Visibility != "" && !c.caps.UserVisibility() {
+		c.log.Warn().Msgf(
+			"User visibility is not supported by the server (%s), sync_config.defaults.user.visibility is ignored",
+			c.caps,
+		)
+	}
+
+	if !c.caps.UserSource() {
+		c.log.Warn().Msgf(
+			"The authentication source of the users is not reported by the server (%s), "+
+				"the users are not deleted",
+			c.caps,
+		)
+	}
+
+	if c.config.LDAP.UserIDAttribute != "" && !c.caps.UserRename() {
+		c.log.Warn().Msgf(
+			"Renaming users is not supported by the server (%s), the renamed ldap users are recreated",
+			c.caps,
+		)
+	}
+
+	for _, u := range c.config.SyncConfig.Defaults.Team.Units {
+		if !c.caps.Unit(u) {
+			c.log.Warn().Msgf(
+				"Repository unit is not supported by the server (%s), it's ignored in sync_config.defaults.team.units: %s",
+				c.caps, u,
+			)
+		}
+	}
+}
+
+// supportedUnits returns the repository units supported by the server.
+func (c *Client) supportedUnits(units []gitea.RepoUnitType) []gitea.RepoUnitType {
+	supported := make([]gitea.RepoUnitType, 0, len(units))
+
+	for _, u := range units {
+		if c.caps.Unit(u) {
+			supported = append(supported, u)
+		}
+	}
+
+	return supported
+}
+
+// Capabilities returns the flavor, the version and the features of the server.
+func (c *Client) Capabilities() *Capabilities {
+	return c.caps
+}
+
+// CurrentUser returns the Gitea user the client is authenticated as.
+func (c *Client) CurrentUser() *User {
+	return c.me
+}
+
+func clientTimeout(conf *config.Config) time.Duration {
+	return time.Duration(conf.Gitea.ClientTimeout) * time.Second
+}
+
+// do executes a single SDK call bound to ctx. Transient failures of the idempotent requests are retried according to
+// the retry policy.
+func (c *Client) do(ctx context.Context, fn func() (*gitea.Response, error)) error {
+	return retry.Do(ctx, retry.NewPolicy(c.config.Retry), "gitea", c.log, func() error {
+		return c.call(ctx, fn)
+	})
+}
+
+// call executes fn once. Every call gets its own timeout configured by gitea.client_timeout.
+func (c *Client) call(ctx context.Context, fn func() (*gitea.Response, error)) error {
+	callCtx, cancel := context.WithTimeout(ctx, clientTimeout(c.config))
+	defer cancel()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.client.SetContext(callCtx)
+	defer c.client.SetContext(context.Background())
+
+	resp, err := fn()
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	if resp == nil || resp.Response == nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && idempotent(requestMethod(resp, err)) {
+			return retry.Retryable(err, 0)
+		}
+
+		return err
+	}
+
+	// A rejected request (429 Too Many Requests) can be sent again even if it's not idempotent.
+	if slices.Contains(c.config.Retry.GiteaStatusCodes, resp.StatusCode) &&
+		(resp.StatusCode == http.StatusTooManyRequests || idempotent(requestMethod(resp, err))) {
+		return retry.Retryable(err, retryAfter(resp.Header))
+	}
+
+	return err
+}
+
+// idempotent reports whether a failed request with the given method can be sent again. A POST (eg.: creating a user,
+// an organization or a team) may have been committed by Gitea even if the response was lost (eg.: a 504 of a proxy),
+// so sending it again would fail with "already exists".
+func idempotent(method string) bool {
+	return method != http.MethodPost
+}
+
+// requestMethod returns the HTTP method of the failed call, or an empty string if it's unknown.
+func requestMethod(resp *gitea.Response, err error) string {
+	if resp != nil && resp.Response != nil && resp.Request != nil {
+		return resp.Request.Method
+	}
+
+	var urlErr *urlpkg.Error
+	if errors.As(err, &urlErr) {
+		return strings.ToUpper(urlErr.Op)
+	}
+
+	return ""
+}
+
+// retryAfter parses the Retry-After header, which is either a number of seconds or an HTTP date.
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+
+	return 0
+}
+
+func (c *Client) AddUsersToTeam(ctx context.Context, users []Account, team int64) error {
 	c.log.Debug().Msgf("Adding users to team: %d", team)
 
 	for i, user := range users {
 		c.log.Debug().Msgf("Processing user: %s", user.FullName)
 
-		foundUsers, _, err := c.client.SearchUsers(
-			gitea.SearchUsersOption{
-				KeyWord: user.FullName,
-			},
-		)
-		if err != nil {
+		var foundUsers []*gitea.User
+
+		if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+			foundUsers, resp, err = c.client.SearchUsers(
+				gitea.SearchUsersOption{
+					KeyWord: user.FullName,
+				},
+			)
+
+			return resp, err
+		}); err != nil {
 			return errors.Wrapf(err, "searching users: %s", user.FullName)
 		}
 
@@ -108,7 +371,9 @@ func (c *Client) AddUsersToTeam(users []Account, team int64) error {
 				continue
 			}
 
-			if _, err = c.client.AddTeamMember(team, users[i].Login); err != nil {
+			if err := c.do(ctx, func() (*gitea.Response, error) {
+				return c.client.AddTeamMember(team, users[i].Login)
+			}); err != nil {
 				return errors.Wrapf(err, "adding user to team: %s (team-id: %d)", user.Login, team)
 			}
 
@@ -121,13 +386,15 @@ func (c *Client) AddUsersToTeam(users []Account, team int64) error {
 	return nil
 }
 
-func (c *Client) DelUsersFromTeam(users []Account, team int64) error {
+func (c *Client) DelUsersFromTeam(ctx context.Context, users []Account, team int64) error {
 	c.log.Debug().Msgf("Removing users from team with id: %d", team)
 
 	for _, user := range users {
 		c.log.Debug().Msgf("Processing user: %s", user.FullName)
 
-		if _, err := c.client.RemoveTeamMember(team, user.FullName); err != nil {
+		if err := c.do(ctx, func() (*gitea.Response, error) {
+			return c.client.RemoveTeamMember(team, user.Login)
+		}); err != nil {
 			return errors.Wrapf(err, "removing user from team: %s (team-id: %d)", user.Login, team)
 		}
 
@@ -139,10 +406,10 @@ func (c *Client) DelUsersFromTeam(users []Account, team int64) error {
 	return nil
 }
 
-func (c *Client) CreateOrganization(o Organization) error {
+func (c *Client) CreateOrganization(ctx context.Context, o Organization) error {
 	c.log.Debug().Msgf("Creating organization: %s", o.UserName)
 
-	exist, err := c.OrganizationExists(o)
+	exist, err := c.OrganizationExists(ctx, o)
 	if err != nil {
 		return err
 	}
@@ -153,17 +420,21 @@ func (c *Client) CreateOrganization(o Organization) error {
 		return nil
 	}
 
-	if _, _, err := c.client.CreateOrg(
-		gitea.CreateOrgOption{
-			Name:                      o.UserName,
-			FullName:                  o.FullName,
-			Description:               o.Description,
-			Website:                   o.Website,
-			Location:                  o.Location,
-			Visibility:                gitea.VisibleType(o.Visibility),
-			RepoAdminChangeTeamAccess: c.config.SyncConfig.Defaults.Organization.RepoAdminChangeTeamAccess,
-		},
-	); err != nil {
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		_, resp, err = c.client.CreateOrg(
+			gitea.CreateOrgOption{
+				Name:                      o.UserName,
+				FullName:                  o.FullName,
+				Description:               c.markDescription(o.Description),
+				Website:                   o.Website,
+				Location:                  o.Location,
+				Visibility:                gitea.VisibleType(o.Visibility),
+				RepoAdminChangeTeamAccess: c.config.SyncConfig.Defaults.Organization.RepoAdminChangeTeamAccess,
+			},
+		)
+
+		return resp, err
+	}); err != nil {
 		return errors.Wrapf(err, "failed to create organization: %s", o.UserName)
 	}
 
@@ -172,10 +443,10 @@ func (c *Client) CreateOrganization(o Organization) error {
 	return nil
 }
 
-func (c *Client) OrganizationExists(o Organization) (bool, error) {
+func (c *Client) OrganizationExists(ctx context.Context, o Organization) (bool, error) {
 	c.log.Debug().Msgf("Checking if organization: %s exists", o.UserName)
 
-	orgs, err := c.ListOrganizations()
+	orgs, err := c.ListOrganizations(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -189,23 +460,32 @@ func (c *Client) OrganizationExists(o Organization) (bool, error) {
 	return false, nil
 }
 
-func (c *Client) DeleteOrganization(orgname string) error {
+func (c *Client) DeleteOrganization(ctx context.Context, orgname string) error {
 	c.log.Debug().Msgf("Deleting organization: %s", orgname)
 
-	repos, _, err := c.client.ListOrgRepos(orgname, gitea.ListOrgReposOptions{})
-	if err != nil {
+	var repos []*gitea.Repository
+
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		repos, resp, err = c.client.ListOrgRepos(orgname, gitea.ListOrgReposOptions{})
+
+		return resp, err
+	}); err != nil {
 		return errors.Wrapf(err, "listing all repositories")
 	}
 
 	for _, repo := range repos {
-		if _, err = c.client.DeleteRepo(orgname, repo.Name); err != nil {
+		if err := c.do(ctx, func() (*gitea.Response, error) {
+			return c.client.DeleteRepo(orgname, repo.Name)
+		}); err != nil {
 			return errors.Wrapf(err, "deleting repository: %s", repo.Name)
 		}
 
 		c.log.Info().Msgf("Repository: %s deleted", repo.Name)
 	}
 
-	if _, err = c.client.DeleteOrg(orgname); err != nil {
+	if err := c.do(ctx, func() (*gitea.Response, error) {
+		return c.client.DeleteOrg(orgname)
+	}); err != nil {
 		return errors.Wrapf(err, "deleting organization: %s", orgname)
 	}
 
@@ -214,10 +494,10 @@ func (c *Client) DeleteOrganization(orgname string) error {
 	return nil
 }
 
-func (c *Client) CreateTeam(orgname string, team Team, opts CreateTeamOpts) error {
+func (c *Client) CreateTeam(ctx context.Context, orgname string, team Team, opts CreateTeamOpts) error {
 	c.log.Debug().Msgf("Creating team in organization: %s (team: %s)", team.Name, orgname)
 
-	exist, err := c.TeamExists(orgname, team)
+	exist, err := c.TeamExists(ctx, orgname, team)
 	if err != nil {
 		return err
 	}
@@ -230,16 +510,20 @@ func (c *Client) CreateTeam(orgname string, team Team, opts CreateTeamOpts) erro
 
 	c.log.Info().Msgf("Creating team in organization: %s (organization: %s)", team.Name, orgname)
 
-	if _, _, err := c.client.CreateTeam(
-		orgname, gitea.CreateTeamOption{
-			Name:                    team.Name,
-			Description:             team.Description,
-			Permission:              opts.Permission,
-			CanCreateOrgRepo:        opts.CanCreateOrgRepo,
-			IncludesAllRepositories: opts.IncludesAllRepositories,
-			Units:                   opts.Units,
-		},
-	); err != nil {
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		_, resp, err = c.client.CreateTeam(
+			orgname, gitea.CreateTeamOption{
+				Name:                    team.Name,
+				Description:             c.markDescription(team.Description),
+				Permission:              opts.Permission,
+				CanCreateOrgRepo:        opts.CanCreateOrgRepo,
+				IncludesAllRepositories: opts.IncludesAllRepositories,
+				Units:                   c.supportedUnits(opts.Units),
+			},
+		)
+
+		return resp, err
+	}); err != nil {
 		return errors.Wrapf(err, "creating team: %s", team.Name)
 	}
 
@@ -248,10 +532,10 @@ func (c *Client) CreateTeam(orgname string, team Team, opts CreateTeamOpts) erro
 	return nil
 }
 
-func (c *Client) TeamExists(orgname string, t Team) (bool, error) {
+func (c *Client) TeamExists(ctx context.Context, orgname string, t Team) (bool, error) {
 	c.log.Debug().Msgf("Checking if team exists in organization: %s (organization: %s)", t.Name, orgname)
 
-	teams, _, err := c.client.ListOrgTeams(orgname, gitea.ListTeamsOptions{})
+	teams, err := c.ListTeams(ctx, orgname)
 	if err != nil {
 		return false, err
 	}
@@ -265,10 +549,12 @@ func (c *Client) TeamExists(orgname string, t Team) (bool, error) {
 	return false, nil
 }
 
-func (c *Client) DeleteTeam(teamID int64) error {
+func (c *Client) DeleteTeam(ctx context.Context, teamID int64) error {
 	c.log.Debug().Msgf("Deleting team with ID: %d", teamID)
 
-	if _, err := c.client.DeleteTeam(teamID); err != nil {
+	if err := c.do(ctx, func() (*gitea.Response, error) {
+		return c.client.DeleteTeam(teamID)
+	}); err != nil {
 		return errors.Wrapf(err, "deleting team-id: %d", teamID)
 	}
 
@@ -277,46 +563,71 @@ func (c *Client) DeleteTeam(teamID int64) error {
 	return nil
 }
 
-func (c *Client) CreateOrUpdateUser(u User) error {
+// CreateOrUpdateUser creates the user in the configured authentication source or updates it. Existing users of other
+// authentication sources are not modified (ErrOtherAuthSource), except the local users with the same email address
+// if the migration of the local users is enabled.
+func (c *Client) CreateOrUpdateUser(ctx context.Context, u User) error {
 	c.log.Debug().Msgf("Creating user: %s", u.UserName)
 
-	exist, err := c.userExists(u)
+	existing, err := c.GetUser(ctx, u.UserName)
 	if err != nil {
 		return err
 	}
 
-	if !exist {
-		if err := c.createUser(u); err != nil {
+	if existing == nil {
+		if err := c.createUser(ctx, u); err != nil {
 			return err
 		}
+
+		return c.updateUser(ctx, u, 0)
 	}
 
-	if err := c.updateUser(u); err != nil {
-		return err
+	if !c.OtherAuthSource(existing.SourceID) {
+		return c.updateUser(ctx, u, 0)
 	}
 
-	return nil
+	if existing.SourceID == 0 && c.config.SyncConfig.MigrateLocalUsers && strings.EqualFold(existing.Email, u.Email) {
+		if err := c.updateUser(ctx, u, c.config.Gitea.AuthSourceID); err != nil {
+			return err
+		}
+
+		c.log.Info().Msgf("Local user migrated to the authentication source: %s", u.UserName)
+
+		return nil
+	}
+
+	return errors.Wrapf(ErrOtherAuthSource, "user: %s (source-id: %d)", u.UserName, existing.SourceID)
+}
+
+// OtherAuthSource reports whether the user with the given authentication source ID (0 for the local users) is not
+// managed by the sync. If the server doesn't report the authentication source of the users, every user is updated,
+// but none of them is deleted (see Capabilities.UserSource).
+func (c *Client) OtherAuthSource(sourceID int64) bool {
+	return c.caps.UserSource() && sourceID != c.config.Gitea.AuthSourceID
 }
 
-func (c *Client) updateUser(user User) error {
+// updateUser updates the user. The user is moved to the authentication source if sourceID is not zero.
+func (c *Client) updateUser(ctx context.Context, user User, sourceID int64) error {
 	c.log.Debug().Msgf("Updating user: %s", user.UserName)
 
-	if _, err := c.client.AdminEditUser(
-		user.UserName, gitea.EditUserOption{
-			LoginName:               user.UserName,
-			Email:                   ptr.To(user.Email),
-			FullName:                ptr.To(user.FullName),
-			MaxRepoCreation:         ptr.To(c.config.SyncConfig.Defaults.User.MaxRepoCreation),
-			AllowCreateOrganization: ptr.To(c.config.SyncConfig.Defaults.User.AllowCreateOrganization),
-			Visibility: ptr.To(
-				gitea.VisibleType(
-					c.config.SyncConfig.Defaults.User.Visibility,
-				),
-			),
-			Admin:      ptr.To(user.IsAdmin),
-			Restricted: ptr.To(user.Restricted),
-		},
-	); err != nil {
+	opt := gitea.EditUserOption{
+		SourceID:                sourceID,
+		LoginName:               loginName(user),
+		Email:                   ptr.To(user.Email),
+		FullName:                ptr.To(user.FullName),
+		MaxRepoCreation:         ptr.To(c.config.SyncConfig.Defaults.User.MaxRepoCreation),
+		AllowCreateOrganization: ptr.To(c.config.SyncConfig.Defaults.User.AllowCreateOrganization),
+		Admin:                   ptr.To(user.IsAdmin),
+		Restricted:              ptr.To(user.Restricted),
+	}
+
+	if c.caps.UserVisibility() {
+		opt.Visibility = ptr.To(gitea.VisibleType(c.config.SyncConfig.Defaults.User.Visibility))
+	}
+
+	if err := c.do(ctx, func() (*gitea.Response, error) {
+		return c.client.AdminEditUser(user.UserName, opt)
+	}); err != nil {
 		return errors.Wrapf(err, "updating user: %s", user.UserName)
 	}
 
@@ -325,39 +636,82 @@ func (c *Client) updateUser(user User) error {
 	return nil
 }
 
-func (c *Client) userExists(u User) (bool, error) {
-	c.log.Debug().Msgf("Checking if user exists: %s", u.UserName)
-
-	users, err := c.ListUsers()
+// RenameUser renames the user, its repositories and its history are kept. The SDK doesn't support renaming users, so
+// the API is called directly.
+func (c *Client) RenameUser(ctx context.Context, username, newName string) error {
+	body, err := json.Marshal(map[string]string{"new_username": newName})
 	if err != nil {
-		return false, err
+		return errors.Wrap(err, "encoding rename request")
 	}
 
-	exist := false
+	if err := c.do(ctx, func() (*gitea.Response, error) {
+		return c.request(ctx, http.MethodPost, "/admin/users/"+urlpkg.PathEscape(username)+"/rename", body)
+	}); err != nil {
+		return errors.Wrapf(err, "renaming user: %s to %s", username, newName)
+	}
 
-	for _, user := range users {
-		if user.UserName == u.UserName {
-			exist = true
+	c.log.Info().Msgf("User renamed: %s to %s", username, newName)
+
+	return nil
+}
+
+// loginName returns the name the user authenticates with, it defaults to the user name.
+func loginName(user User) string {
+	if user.LoginName != "" {
+		return user.LoginName
+	}
+
+	return user.UserName
+}
+
+// GetUser returns the user or nil if it doesn't exist.
+func (c *Client) GetUser(ctx context.Context, username string) (*User, error) {
+	c.log.Debug().Msgf("Checking if user exists: %s", username)
+
+	var (
+		user   *User
+		status int
+	)
+
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		user, resp, err = c.client.GetUserInfo(username)
+		if resp != nil {
+			status = resp.StatusCode
 		}
+
+		return resp, err
+	}); err != nil {
+		if status == http.StatusNotFound {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, "getting user: %s", username)
 	}
 
-	return exist, nil
+	return user, nil
 }
 
-func (c *Client) createUser(user User) error {
+func (c *Client) createUser(ctx context.Context, user User) error {
 	c.log.Debug().Msgf("Creating user: %s", user.UserName)
 
-	if _, _, err := c.client.AdminCreateUser(
-		gitea.CreateUserOption{
-			LoginName:          user.UserName,
-			Username:           user.UserName,
-			FullName:           user.FullName,
-			Email:              user.Email,
-			MustChangePassword: ptr.To(false),
-			Visibility:         ptr.To(user.Visibility),
-			SourceID:           c.config.Gitea.AuthSourceID,
-		},
-	); err != nil {
+	opt := gitea.CreateUserOption{
+		LoginName:          loginName(user),
+		Username:           user.UserName,
+		FullName:           user.FullName,
+		Email:              user.Email,
+		MustChangePassword: ptr.To(false),
+		SourceID:           c.config.Gitea.AuthSourceID,
+	}
+
+	if c.caps.UserVisibility() {
+		opt.Visibility = ptr.To(user.Visibility)
+	}
+
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		_, resp, err = c.client.AdminCreateUser(opt)
+
+		return resp, err
+	}); err != nil {
 		if strings.Contains(err.Error(), "already exists") {
 			return nil
 		}
@@ -370,14 +724,16 @@ func (c *Client) createUser(user User) error {
 	return nil
 }
 
-func (c *Client) DeleteUser(username string) error {
+func (c *Client) DeleteUser(ctx context.Context, username string) error {
 	c.log.Debug().Msgf("Deleting user: %s", username)
 
-	if err := c.RemoveUserFromAllTeams(username); err != nil {
+	if err := c.RemoveUserFromAllTeams(ctx, username); err != nil {
 		return errors.Wrapf(err, "removing user from all teams: %s", username)
 	}
 
-	if _, err := c.client.AdminDeleteUser(username); err != nil {
+	if err := c.do(ctx, func() (*gitea.Response, error) {
+		return c.client.AdminDeleteUser(username)
+	}); err != nil {
 		return errors.Wrapf(err, "deleting user: %s", username)
 	}
 
@@ -386,28 +742,35 @@ func (c *Client) DeleteUser(username string) error {
 	return nil
 }
 
-func (c *Client) RemoveUserFromAllTeams(username string) error {
+func (c *Client) RemoveUserFromAllTeams(ctx context.Context, username string) error {
 	c.log.Debug().Msgf("Removing user from all teams: %s", username)
 
-	orgs, _, err := c.client.ListUserOrgs(username, gitea.ListOrgsOptions{})
-	if err != nil {
+	var orgs []*gitea.Organization
+
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		orgs, resp, err = c.client.ListUserOrgs(username, gitea.ListOrgsOptions{})
+
+		return resp, err
+	}); err != nil {
 		return errors.Wrapf(err, "listing all orgs for user: %s", username)
 	}
 
 	for _, org := range orgs {
-		teams, _, err := c.client.ListOrgTeams(org.UserName, gitea.ListTeamsOptions{})
+		teams, err := c.ListTeams(ctx, org.UserName)
 		if err != nil {
 			return errors.Wrapf(err, "listing all teams for org: %s", org.UserName)
 		}
 
 		for _, team := range teams {
-			isTeamMember, err := c.IsTeamMember(username, team.ID)
+			isTeamMember, err := c.IsTeamMember(ctx, username, team.ID)
 			if err != nil {
 				return errors.Wrapf(err, "checking if user is a member of team: %s (team-id: %d)", username, team.ID)
 			}
 
 			if isTeamMember {
-				if _, err := c.client.RemoveTeamMember(team.ID, username); err != nil {
+				if err := c.do(ctx, func() (*gitea.Response, error) {
+					return c.client.RemoveTeamMember(team.ID, username)
+				}); err != nil {
 					return errors.Wrapf(err, "removing user from team: %s (team-id: %d)", username, team.ID)
 				}
 			}
@@ -419,20 +782,35 @@ func (c *Client) RemoveUserFromAllTeams(username string) error {
 	return nil
 }
 
-func (c *Client) IsTeamMember(username string, teamID int64) (bool, error) {
-	member, _, err := c.client.GetTeamMember(teamID, username)
-	if err != nil {
+func (c *Client) IsTeamMember(ctx context.Context, username string, teamID int64) (bool, error) {
+	var member *gitea.User
+
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		member, resp, err = c.client.GetTeamMember(teamID, username)
+
+		return resp, err
+	}); err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+
 		return false, nil
 	}
 
 	return member != nil, nil
 }
 
-func (c *Client) ListTeamUsers(teamID int64) (map[string]Account, error) {
-	var accounts = make(map[string]Account)
+func (c *Client) ListTeamUsers(ctx context.Context, teamID int64) (map[string]Account, error) {
+	var (
+		accounts = make(map[string]Account)
+		users    []*gitea.User
+	)
 
-	users, _, err := c.client.ListTeamMembers(teamID, gitea.ListTeamMembersOptions{})
-	if err != nil {
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		users, resp, err = c.client.ListTeamMembers(teamID, gitea.ListTeamMembersOptions{})
+
+		return resp, err
+	}); err != nil {
 		return nil, errors.Wrap(err, "listing all team members")
 	}
 
@@ -442,33 +820,49 @@ func (c *Client) ListTeamUsers(teamID int64) (map[string]Account, error) {
 			ID:       int(user.ID),
 			FullName: user.FullName,
 			Login:    user.UserName,
+			SourceID: user.SourceID,
 		}
 	}
 
 	return accounts, nil
 }
 
-func (c *Client) ListOrganizations() (Organizations, error) {
-	orgs, _, err := c.client.AdminListOrgs(gitea.AdminListOrgsOptions{})
-	if err != nil {
+func (c *Client) ListOrganizations(ctx context.Context) (Organizations, error) {
+	var orgs []*gitea.Organization
+
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		orgs, resp, err = c.client.AdminListOrgs(gitea.AdminListOrgsOptions{})
+
+		return resp, err
+	}); err != nil {
 		return nil, errors.Wrap(err, "listing all organizations")
 	}
 
 	return orgs, nil
 }
 
-func (c *Client) ListTeams(orgname string) ([]*gitea.Team, error) {
-	teams, _, err := c.client.ListOrgTeams(orgname, gitea.ListTeamsOptions{})
-	if err != nil {
+func (c *Client) ListTeams(ctx context.Context, orgname string) ([]*gitea.Team, error) {
+	var teams []*gitea.Team
+
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		teams, resp, err = c.client.ListOrgTeams(orgname, gitea.ListTeamsOptions{})
+
+		return resp, err
+	}); err != nil {
 		return nil, errors.Wrap(err, "listing all teams")
 	}
 
 	return teams, nil
 }
 
-func (c *Client) ListUsers() ([]*User, error) {
-	users, _, err := c.client.AdminListUsers(gitea.AdminListUsersOptions{})
-	if err != nil {
+func (c *Client) ListUsers(ctx context.Context) ([]*User, error) {
+	var users []*User
+
+	if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+		users, resp, err = c.client.AdminListUsers(gitea.AdminListUsersOptions{})
+
+		return resp, err
+	}); err != nil {
 		return nil, errors.Wrap(err, "listing all users")
 	}
 
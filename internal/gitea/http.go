@@ -0,0 +1,128 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	urlpkg "net/url"
+	"os"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/pkg/errors"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+)
+
+// newHTTPClient builds the HTTP client used by the SDK from the gitea settings.
+func newHTTPClient(conf *config.Config) (*http.Client, error) {
+	c := conf.Gitea
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("unexpected default http transport")
+	}
+
+	transport = transport.Clone()
+
+	//nolint:gosec // allowInsecureTLS should be used with caution.
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.AllowInsecureTLS,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading gitea ca file: %s", c.CAFile)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in gitea ca file: %s", c.CAFile)
+		}
+
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	if c.ProxyURL != "" {
+		proxy, err := urlpkg.Parse(c.ProxyURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing gitea proxy url: %s", c.ProxyURL)
+		}
+
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	var rt http.RoundTripper = transport
+	if len(c.Headers) > 0 {
+		rt = &headerTransport{headers: c.Headers, next: transport}
+	}
+
+	return &http.Client{
+		Transport: rt,
+		Timeout:   clientTimeout(conf),
+	}, nil
+}
+
+// headerTransport adds custom headers (eg.: Cloudflare Access tokens) to every request.
+type headerTransport struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	for k, v := range t.headers {
+		if req.Header.Get(k) == "" {
+			req.Header.Set(k, v)
+		}
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// maxErrorBodySize is the maximum number of bytes of an error response included in the error.
+const maxErrorBodySize = 1024
+
+// request calls an API endpoint not supported by the SDK with the credentials of the client. The path is relative to
+// /api/v1. A response with an error status is returned as an error.
+func (c *Client) request(ctx context.Context, method, path string, body []byte) (*gitea.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/api/v1"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating request: %s %s", method, path)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.config.Gitea.GetAuthMethod() == config.AuthMethodBasic {
+		req.SetBasicAuth(c.config.Gitea.User, c.config.Gitea.Password)
+	} else {
+		req.Header.Set("Authorization", "token "+c.config.Gitea.Token)
+	}
+
+	if c.config.Gitea.Sudo != "" {
+		req.Header.Set("Sudo", c.config.Gitea.Sudo)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &gitea.Response{Response: resp}, errors.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return &gitea.Response{Response: resp}, nil
+}
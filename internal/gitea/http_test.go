@@ -0,0 +1,258 @@
+package gitea_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/gitea"
+)
+
+// fakeServer is a minimal Gitea API recording the requests it receives.
+type fakeServer struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	// bodies are the request bodies by the path.
+	bodies map[string][]byte
+	admin  bool
+	// failures are the status codes returned before the request is served by "<method> <path>".
+	failures map[string][]int
+	// stall blocks the requests of the users until the client gives up.
+	stall bool
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	payload, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Clone(context.Background()))
+
+	if s.bodies == nil {
+		s.bodies = make(map[string][]byte)
+	}
+
+	s.bodies[r.URL.Path] = payload
+
+	if s.stall && strings.HasPrefix(r.URL.Path, "/api/v1/users/") {
+		s.mu.Unlock()
+		<-r.Context().Done()
+
+		return
+	}
+
+	key := r.Method + " " + r.URL.Path
+	if codes := s.failures[key]; len(codes) != 0 {
+		s.failures[key] = codes[1:]
+		s.mu.Unlock()
+
+		http.Error(w, http.StatusText(codes[0]), codes[0])
+
+		return
+	}
+
+	s.mu.Unlock()
+
+	var body any
+
+	status := http.StatusOK
+
+	switch {
+	case r.URL.Path == "/api/v1/version":
+		body = map[string]any{"version": "1.22.3"}
+	case r.URL.Path == "/api/v1/user":
+		body = map[string]any{"id": 1, "login": "admin", "is_admin": s.admin}
+	case strings.HasPrefix(r.URL.Path, "/api/v1/users/"):
+		body = map[string]any{"id": 2, "login": strings.TrimPrefix(r.URL.Path, "/api/v1/users/")}
+	case strings.HasPrefix(r.URL.Path, "/api/v1/teams/"):
+		body = json.RawMessage(payload)
+	case r.URL.Path == "/api/v1/admin/orgs":
+		body = []any{}
+	case r.URL.Path == "/api/v1/orgs" && r.Method == http.MethodPost:
+		status, body = http.StatusCreated, json.RawMessage(payload)
+	default:
+		http.NotFound(w, r)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// count returns the number of the recorded requests of the method and the path.
+func (s *fakeServer) count(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+
+	for _, r := range s.requests {
+		if r.Method == method && r.URL.Path == path {
+			n++
+		}
+	}
+
+	return n
+}
+
+// request returns the first recorded request of the path.
+func (s *fakeServer) request(t *testing.T, path string) *http.Request {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.requests {
+		if r.URL.Path == path {
+			return r
+		}
+	}
+
+	t.Fatalf("no request to %s", path)
+
+	return nil
+}
+
+// body returns the decoded body of the last request of the path.
+func (s *fakeServer) body(t *testing.T, path string) map[string]any {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var v map[string]any
+	if err := json.Unmarshal(s.bodies[path], &v); err != nil {
+		t.Fatalf("decoding the body of %s: %v", path, err)
+	}
+
+	return v
+}
+
+// newConfig returns the configuration of a client connecting to the server.
+func newConfig(url string) *config.Config {
+	return &config.Config{
+		Gitea: &config.GiteaConfig{
+			BaseURL:       url,
+			AuthMethod:    config.AuthMethodToken,
+			Token:         "exampleToken",
+			ClientTimeout: 10,
+		},
+		LDAP:       &config.LDAPConfig{},
+		SyncConfig: &config.SyncConfig{},
+		Retry:      &config.RetryConfig{MaxAttempts: 1},
+	}
+}
+
+func TestNewHeaders(t *testing.T) {
+	t.Parallel()
+
+	server := &fakeServer{admin: true}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	conf := newConfig(srv.URL)
+	conf.Gitea.Headers = map[string]string{
+		"CF-Access-Client-Id": "example.access",
+		"Authorization":       "Bearer other",
+	}
+
+	c, err := gitea.New(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := c.GetUser(context.Background(), "jdoe"); err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+
+	for _, path := range []string{"/api/v1/version", "/api/v1/user", "/api/v1/users/jdoe"} {
+		r := server.request(t, path)
+
+		if got := r.Header.Get("CF-Access-Client-Id"); got != "example.access" {
+			t.Errorf("%s: CF-Access-Client-Id = %q, want %q", path, got, "example.access")
+		}
+
+		if got := r.Header.Get("Authorization"); got != "token exampleToken" {
+			t.Errorf("%s: Authorization = %q, want the credentials of the client", path, got)
+		}
+	}
+}
+
+func TestNewCAFile(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(&fakeServer{admin: true})
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(
+		caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	emptyFile := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyFile, []byte("no certificates"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		caFile   string
+		insecure bool
+		wantErr  string
+	}{
+		{
+			name:   "Test if the server is trusted using the ca file",
+			caFile: caFile,
+		},
+		{
+			name:    "Test if the server is not trusted without the ca file",
+			wantErr: "certificate",
+		},
+		{
+			name:     "Test if the certificate is not verified if insecure tls is allowed",
+			insecure: true,
+		},
+		{
+			name:    "Test if a ca file without certificates is rejected",
+			caFile:  emptyFile,
+			wantErr: "no certificates found in gitea ca file",
+		},
+		{
+			name:    "Test if a missing ca file is rejected",
+			caFile:  filepath.Join(dir, "missing.pem"),
+			wantErr: "reading gitea ca file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf := newConfig(srv.URL)
+			conf.Gitea.CAFile = tt.caFile
+			conf.Gitea.AllowInsecureTLS = tt.insecure
+
+			_, err := gitea.New(context.Background(), conf)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("New() error = %v, wantErr %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/pkg/errors"
+)
+
+// maxDescriptionLength is the longest description of the organizations and teams accepted by Gitea.
+const maxDescriptionLength = 255
+
+// IsManaged reports whether the organization or team with the given description is managed by the sync. Objects
+// created by the sync (or adopted by the import command) have the ownership marker in their description. If the
+// marker is disabled, every object is managed.
+func (c *Client) IsManaged(description string) bool {
+	marker := c.config.SyncConfig.OwnershipMarker
+
+	return marker == "" || strings.Contains(description, marker)
+}
+
+// markDescription appends the ownership marker to the description. The description is truncated if needed, so the
+// marker always fits.
+func (c *Client) markDescription(description string) string {
+	marker := c.config.SyncConfig.OwnershipMarker
+	if marker == "" || strings.Contains(description, marker) {
+		return description
+	}
+
+	if description == "" {
+		return marker
+	}
+
+	limit := maxDescriptionLength - len(marker) - 1
+	if limit < 0 {
+		return marker
+	}
+
+	if len(description) > limit {
+		description = strings.ToValidUTF8(description[:limit], "")
+	}
+
+	return description + " " + marker
+}
+
+// AdoptOrganization adds the ownership marker to an existing organization, so it's managed by the sync.
+func (c *Client) AdoptOrganization(ctx context.Context, o *Organization) error {
+	if err := c.do(ctx, func() (*gitea.Response, error) {
+		return c.client.EditOrg(o.UserName, gitea.EditOrgOption{
+			FullName:    o.FullName,
+			Description: c.markDescription(o.Description),
+			Website:     o.Website,
+			Location:    o.Location,
+			Visibility:  gitea.VisibleType(o.Visibility),
+		})
+	}); err != nil {
+		return errors.Wrapf(err, "adopting organization: %s", o.UserName)
+	}
+
+	c.log.Info().Msgf("Organization adopted: %s", o.UserName)
+
+	return nil
+}
+
+// AdoptTeam adds the ownership marker to an existing team, so it's managed by the sync. Only the description is
+// sent: the SDK would send the permission and the units as well, which overwrites the per-unit permissions of the team.
+func (c *Client) AdoptTeam(ctx context.Context, t *Team) error {
+	body, err := json.Marshal(map[string]string{
+		"name":        t.Name,
+		"description": c.markDescription(t.Description),
+	})
+	if err != nil {
+		return errors.Wrap(err, "encoding team")
+	}
+
+	if err := c.do(ctx, func() (*gitea.Response, error) {
+		return c.request(ctx, http.MethodPatch, fmt.Sprintf("/teams/%d", t.ID), body)
+	}); err != nil {
+		return errors.Wrapf(err, "adopting team: %s (team-id: %d)", t.Name, t.ID)
+	}
+
+	c.log.Info().Msgf("Team adopted: %s (team-id: %d)", t.Name, t.ID)
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package gitea_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	giteapkg "code.gitea.io/sdk/gitea"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/gitea"
+)
+
+func TestAdoptTeam(t *testing.T) {
+	t.Parallel()
+
+	server := &fakeServer{admin: true}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	conf := newConfig(srv.URL)
+	conf.SyncConfig.OwnershipMarker = "[managed]"
+
+	c, err := gitea.New(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	team := &gitea.Team{
+		ID:          7,
+		Name:        "backend",
+		Description: "Backend developers",
+		Permission:  giteapkg.AccessModeWrite,
+		Units:       []giteapkg.RepoUnitType{giteapkg.RepoUnitCode},
+	}
+
+	if err := c.AdoptTeam(context.Background(), team); err != nil {
+		t.Fatalf("AdoptTeam() error = %v", err)
+	}
+
+	// The permission and the units are not sent, so the per-unit permissions of the team are kept.
+	want := map[string]any{"name": "backend", "description": "Backend developers [managed]"}
+	if got := server.body(t, "/api/v1/teams/7"); !reflect.DeepEqual(got, want) {
+		t.Errorf("AdoptTeam() sent %v, want %v", got, want)
+	}
+
+	if got := server.request(t, "/api/v1/teams/7").Method; got != http.MethodPatch {
+		t.Errorf("AdoptTeam() method = %s, want PATCH", got)
+	}
+}
@@ -0,0 +1,156 @@
+package gitea
+
+import (
+	"context"
+	"net/http"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/pkg/errors"
+)
+
+// listPageSize is the number of objects requested per page.
+const listPageSize = 50
+
+// ListOrgRepositories returns the names of the repositories of the organization.
+func (c *Client) ListOrgRepositories(ctx context.Context, orgname string) ([]string, error) {
+	var names []string
+
+	for page := 1; ; page++ {
+		var repos []*gitea.Repository
+
+		if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+			repos, resp, err = c.client.ListOrgRepos(
+				orgname, gitea.ListOrgReposOptions{ListOptions: gitea.ListOptions{Page: page, PageSize: listPageSize}},
+			)
+
+			return resp, err
+		}); err != nil {
+			return nil, errors.Wrapf(err, "listing the repositories of organization: %s", orgname)
+		}
+
+		for _, r := range repos {
+			names = append(names, r.Name)
+		}
+
+		if len(repos) < listPageSize {
+			return names, nil
+		}
+	}
+}
+
+// ListTeamRepositories returns the names of the repositories the team has access to.
+func (c *Client) ListTeamRepositories(ctx context.Context, teamID int64) ([]string, error) {
+	var names []string
+
+	for page := 1; ; page++ {
+		var repos []*gitea.Repository
+
+		if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+			repos, resp, err = c.client.ListTeamRepositories(
+				teamID, gitea.ListTeamRepositoriesOptions{ListOptions: gitea.ListOptions{Page: page, PageSize: listPageSize}},
+			)
+
+			return resp, err
+		}); err != nil {
+			return nil, errors.Wrapf(err, "listing the repositories of team: %d", teamID)
+		}
+
+		for _, r := range repos {
+			names = append(names, r.Name)
+		}
+
+		if len(repos) < listPageSize {
+			return names, nil
+		}
+	}
+}
+
+// AddTeamRepository gives the team access to the repository of the organization.
+func (c *Client) AddTeamRepository(ctx context.Context, teamID int64, orgname, repo string) error {
+	if err := c.do(ctx, func() (*gitea.Response, error) {
+		return c.client.AddTeamRepository(teamID, orgname, repo)
+	}); err != nil {
+		return errors.Wrapf(err, "adding repository to team: %s/%s (team-id: %d)", orgname, repo, teamID)
+	}
+
+	c.log.Info().Msgf("Repository: %s/%s added to team: %d", orgname, repo, teamID)
+
+	return nil
+}
+
+// RemoveTeamRepository revokes the access of the team to the repository of the organization.
+func (c *Client) RemoveTeamRepository(ctx context.Context, teamID int64, orgname, repo string) error {
+	if err := c.do(ctx, func() (*gitea.Response, error) {
+		return c.client.RemoveTeamRepository(teamID, orgname, repo)
+	}); err != nil {
+		return errors.Wrapf(err, "removing repository from team: %s/%s (team-id: %d)", orgname, repo, teamID)
+	}
+
+	c.log.Info().Msgf("Repository: %s/%s removed from team: %d", orgname, repo, teamID)
+
+	return nil
+}
+
+// ListCollaborators returns the logins of the collaborators of the repository. ErrNotFound is returned if the
+// repository does not exist.
+func (c *Client) ListCollaborators(ctx context.Context, owner, repo string) ([]string, error) {
+	var logins []string
+
+	for page := 1; ; page++ {
+		var (
+			users  []*gitea.User
+			status int
+		)
+
+		if err := c.do(ctx, func() (resp *gitea.Response, err error) {
+			users, resp, err = c.client.ListCollaborators(
+				owner, repo, gitea.ListCollaboratorsOptions{ListOptions: gitea.ListOptions{Page: page, PageSize: listPageSize}},
+			)
+			if resp != nil {
+				status = resp.StatusCode
+			}
+
+			return resp, err
+		}); err != nil {
+			if status == http.StatusNotFound {
+				return nil, errors.Wrapf(ErrNotFound, "repository: %s/%s", owner, repo)
+			}
+
+			return nil, errors.Wrapf(err, "listing the collaborators of repository: %s/%s", owner, repo)
+		}
+
+		for _, u := range users {
+			logins = append(logins, u.UserName)
+		}
+
+		if len(users) < listPageSize {
+			return logins, nil
+		}
+	}
+}
+
+// AddCollaborator adds the user to the collaborators of the repository or updates its permission.
+func (c *Client) AddCollaborator(ctx context.Context, owner, repo, login string, permission gitea.AccessMode) error {
+	if err := c.do(ctx, func() (*gitea.Response, error) {
+		return c.client.AddCollaborator(owner, repo, login, gitea.AddCollaboratorOption{Permission: &permission})
+	}); err != nil {
+		return errors.Wrapf(err, "adding collaborator to repository: %s/%s (user: %s)", owner, repo, login)
+	}
+
+	c.log.Info().Msgf("Collaborator: %s added to repository: %s/%s (permission: %s)", login, owner, repo, permission)
+
+	return nil
+}
+
+// DeleteCollaborator removes the user from the collaborators of the repository.
+func (c *Client) DeleteCollaborator(ctx context.Context, owner, repo, login string) error {
+	if err := c.do(ctx, func() (*gitea.Response, error) {
+		return c.client.DeleteCollaborator(owner, repo, login)
+	}); err != nil {
+		return errors.Wrapf(err, "removing collaborator from repository: %s/%s (user: %s)", owner, repo, login)
+	}
+
+	c.log.Info().Msgf("Collaborator: %s removed from repository: %s/%s", login, owner, repo)
+
+	return nil
+}
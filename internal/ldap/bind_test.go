@@ -0,0 +1,171 @@
+package ldap_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+)
+
+// bindRequest is a bind request received by the fake LDAP server.
+type bindRequest struct {
+	name      string
+	password  string
+	mechanism string
+}
+
+// serveBind answers the bind requests on the connection with the result code and sends the requests to the channel.
+func serveBind(conn net.Conn, resultCode int64, requests chan<- bindRequest) {
+	defer close(requests)
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		if len(packet.Children) < 2 || packet.Children[1].Tag != goldap.ApplicationBindRequest {
+			return
+		}
+
+		req := packet.Children[1]
+		r := bindRequest{name: req.Children[1].Data.String()}
+
+		switch auth := req.Children[2]; auth.Tag {
+		case 0:
+			r.password = auth.Data.String()
+		case 3:
+			r.mechanism = auth.Children[0].Data.String()
+		}
+
+		requests <- r
+
+		if _, err := conn.Write(result(packet, goldap.ApplicationBindResponse, resultCode).Bytes()); err != nil {
+			return
+		}
+	}
+}
+
+// envelope returns the envelope of the response to the request packet.
+func envelope(request *ber.Packet) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(request.Children[0])
+
+	return p
+}
+
+// result returns the response to the request packet with the result code (eg.: a bind response).
+func result(request *ber.Packet, tag ber.Tag, resultCode int64) *ber.Packet {
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	res.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, resultCode, "Result"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Message"))
+
+	p := envelope(request)
+	p.AppendChild(res)
+
+	return p
+}
+
+func TestConfigureBind(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		config     config.LDAPConfig
+		resultCode int64
+		want       *bindRequest
+		wantErr    string
+	}{
+		{
+			name: "Test if the simple bind sends the bind dn and the password",
+			config: config.LDAPConfig{
+				BindMethod: config.BindMethodSimple, BindDN: "cn=admin,dc=example,dc=com", BindPassword: "secret",
+			},
+			want: &bindRequest{name: "cn=admin,dc=example,dc=com", password: "secret"},
+		},
+		{
+			name:   "Test if the simple bind without bind dn is anonymous",
+			config: config.LDAPConfig{BindMethod: config.BindMethodSimple},
+			want:   &bindRequest{},
+		},
+		{
+			name:   "Test if the external bind uses SASL EXTERNAL",
+			config: config.LDAPConfig{BindMethod: config.BindMethodExternal},
+			want:   &bindRequest{mechanism: "EXTERNAL"},
+		},
+		{
+			name: "Test if invalid credentials are reported",
+			config: config.LDAPConfig{
+				BindMethod: config.BindMethodSimple, BindDN: "cn=admin,dc=example,dc=com", BindPassword: "wrong",
+			},
+			resultCode: goldap.LDAPResultInvalidCredentials,
+			want:       &bindRequest{name: "cn=admin,dc=example,dc=com", password: "wrong"},
+			wantErr:    "failed to bind with binddn: cn=admin,dc=example,dc=com",
+		},
+		{
+			name:       "Test if the failed external bind is reported",
+			config:     config.LDAPConfig{BindMethod: config.BindMethodExternal},
+			resultCode: goldap.LDAPResultInappropriateAuthentication,
+			want:       &bindRequest{mechanism: "EXTERNAL"},
+			wantErr:    "failed to bind using SASL EXTERNAL",
+		},
+		{
+			name: "Test if the gssapi bind fails without a keytab",
+			config: config.LDAPConfig{
+				BindMethod: config.BindMethodGSSAPI, KerberosUsername: "sync", KerberosRealm: "EXAMPLE.COM",
+				KerberosKeytabFile: "/nonexistent/sync.keytab", KerberosConfigFile: "/nonexistent/krb5.conf",
+			},
+			wantErr: "creating kerberos client for: sync@EXAMPLE.COM",
+		},
+		{
+			name:    "Test if an unknown bind method is rejected",
+			config:  config.LDAPConfig{BindMethod: "ntlm"},
+			wantErr: "unknown ldap bind method: ntlm",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, server := net.Pipe()
+			requests := make(chan bindRequest, 1)
+
+			go serveBind(server, tt.resultCode, requests)
+
+			l := goldap.NewConn(client, false)
+			l.Start()
+
+			err := ldap.ConfigureBind(l, &tt.config, ldap.Endpoint{Addr: "ldap.example.com:389"})
+
+			l.Close()
+			server.Close()
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("ConfigureBind() error = %v", err)
+			}
+
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ConfigureBind() error = %v, wantErr %q", err, tt.wantErr)
+			}
+
+			got, ok := <-requests
+			if tt.want == nil {
+				if ok {
+					t.Errorf("ConfigureBind() sent a bind request: %+v", got)
+				}
+
+				return
+			}
+
+			if got != *tt.want {
+				t.Errorf("ConfigureBind() sent %+v, want %+v", got, *tt.want)
+			}
+		})
+	}
+}
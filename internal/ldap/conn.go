@@ -0,0 +1,407 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/go-ldap/ldap/v3/gssapi"
+	"github.com/pkg/errors"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/logger"
+	"github.com/janosmiko/gitea-ldap-sync/internal/retry"
+)
+
+func New(ctx context.Context, conf *config.Config) (*Client, error) {
+	templates, err := newTemplates(conf.LDAP)
+	if err != nil {
+		return nil, err
+	}
+
+	ldapClient := &Client{
+		config:    conf,
+		templates: templates,
+		log:       logger.New().Tag("ldap"),
+	}
+
+	if err := ldapClient.connect(ctx); err != nil {
+		return nil, err
+	}
+
+	return ldapClient, nil
+}
+
+func (c *Client) Close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// connect dials the configured LDAP servers in order and binds to the first one available.
+func (c *Client) connect(ctx context.Context) error {
+	servers, err := c.endpoints(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+
+	for _, server := range servers {
+		l, err := NewLDAPConn(ctx, c.config, server)
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("LDAP server is not available: %s", server.Addr)
+			errs = append(errs, err.Error())
+
+			continue
+		}
+
+		l.SetTimeout(c.config.LDAP.OperationTimeout)
+
+		if err := configureBind(l, c.config.LDAP, server); err != nil {
+			l.Close()
+			c.log.Warn().Err(err).Msgf("Failed to bind to LDAP server: %s", server.Addr)
+			errs = append(errs, err.Error())
+
+			continue
+		}
+
+		c.log.Debug().Msgf("Connected to LDAP server: %s (tls mode: %s)", server.Addr, server.TLSMode)
+		c.conn = l
+
+		return nil
+	}
+
+	return errors.Errorf("failed to connect to any of the LDAP servers: %s", strings.Join(errs, "; "))
+}
+
+// ensureConnected re-dials and re-binds if the connection was closed (eg.: by an idle timeout or a failover).
+func (c *Client) ensureConnected(ctx context.Context) error {
+	if c.conn != nil && !c.conn.IsClosing() {
+		return nil
+	}
+
+	if c.conn != nil {
+		c.log.Info().Msg("LDAP connection is closed, reconnecting")
+		c.Close()
+	}
+
+	return c.connect(ctx)
+}
+
+// NewLDAPConn dials the LDAP server and sets up TLS according to the TLS mode of the endpoint. The dial is aborted
+// when ctx is done or the connect timeout is exceeded.
+func NewLDAPConn(ctx context.Context, c *config.Config, server Endpoint) (*ldap.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.LDAP.ConnectTimeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	addr, mode := server.Addr, server.TLSMode
+
+	if mode == config.TLSModeNone {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to dial non-TLS: %s", addr)
+		}
+
+		l := ldap.NewConn(conn, false)
+		l.Start()
+
+		return l, nil
+	}
+
+	tlsConfig, err := newTLSConfig(c.LDAP, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case config.TLSModeLDAPS:
+		tlsDialer := &tls.Dialer{
+			NetDialer: dialer,
+			Config:    tlsConfig,
+		}
+
+		conn, err := tlsDialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to dial TLS: %s", addr)
+		}
+
+		l := ldap.NewConn(conn, true)
+		l.Start()
+
+		return l, nil
+	case config.TLSModeStartTLS:
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to dial: %s", addr)
+		}
+
+		l := ldap.NewConn(conn, false)
+		l.Start()
+
+		if err := l.StartTLS(tlsConfig); err != nil {
+			l.Close()
+
+			return nil, errors.Wrapf(err, "failed to start TLS: %s", addr)
+		}
+
+		return l, nil
+	default:
+		return nil, errors.Errorf("unknown ldap tls mode: %s", mode)
+	}
+}
+
+func newTLSConfig(c *config.LDAPConfig, addr string) (*tls.Config, error) {
+	serverName := c.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing ldap address: %s", addr)
+		}
+
+		serverName = host
+	}
+
+	minVersion, err := tlsVersion(c.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	//nolint:gosec // allowInsecureTLS should be used with caution.
+	tlsConfig := &tls.Config{
+		ServerName:         serverName,
+		MinVersion:         minVersion,
+		InsecureSkipVerify: c.AllowInsecureTLS,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading ldap ca file: %s", c.CAFile)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in ldap ca file: %s", c.CAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(
+				err, "loading ldap client certificate: %s (key: %s)", c.ClientCertFile, c.ClientKeyFile,
+			)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func tlsVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, errors.Errorf("unknown tls version: %s", v)
+	}
+}
+
+// configureBind authenticates the connection using the configured bind method.
+func configureBind(l *ldap.Conn, c *config.LDAPConfig, server Endpoint) error {
+	switch c.GetBindMethod() {
+	case config.BindMethodExternal:
+		if err := l.ExternalBind(); err != nil {
+			return errors.Wrap(err, "failed to bind using SASL EXTERNAL")
+		}
+
+		return nil
+	case config.BindMethodGSSAPI:
+		return gssapiBind(l, c, server)
+	case config.BindMethodSimple:
+		if len(c.BindDN) == 0 {
+			if err := l.UnauthenticatedBind(""); err != nil {
+				return errors.Wrap(err, "failed to set unauthenticated bind")
+			}
+
+			return nil
+		}
+
+		if err := l.Bind(c.BindDN, c.BindPassword); err != nil {
+			return errors.Wrapf(err, "failed to bind with binddn: %s", c.BindDN)
+		}
+
+		return nil
+	default:
+		return errors.Errorf("unknown ldap bind method: %s", c.BindMethod)
+	}
+}
+
+// gssapiBind authenticates using Kerberos with the credentials of the configured keytab.
+func gssapiBind(l *ldap.Conn, c *config.LDAPConfig, server Endpoint) error {
+	client, err := gssapi.NewClientWithKeytab(
+		c.KerberosUsername, c.KerberosRealm, c.KerberosKeytabFile, c.KerberosConfigFile,
+	)
+	if err != nil {
+		return errors.Wrapf(err, "creating kerberos client for: %s@%s", c.KerberosUsername, c.KerberosRealm)
+	}
+
+	defer client.Close()
+
+	spn := c.KerberosSPN
+	if spn == "" {
+		host, _, err := net.SplitHostPort(server.Addr)
+		if err != nil {
+			return errors.Wrapf(err, "parsing ldap address: %s", server.Addr)
+		}
+
+		spn = "ldap/" + host
+	}
+
+	if err := l.GSSAPIBind(client, spn, ""); err != nil {
+		return errors.Wrapf(err, "failed to bind using SASL GSSAPI (spn: %s)", spn)
+	}
+
+	return nil
+}
+
+// HealthCheck reads the root DSE of the server to verify that the connection is usable. A broken connection is
+// replaced by a new one.
+func (c *Client) HealthCheck(ctx context.Context) error {
+	if err := c.ensureConnected(ctx); err != nil {
+		return err
+	}
+
+	if _, err := c.search(ctx, "", "(objectClass=*)", ldap.ScopeBaseObject); err != nil {
+		c.log.Warn().Err(err).Msg("LDAP health check failed, reconnecting")
+		c.Close()
+
+		return c.ensureConnected(ctx)
+	}
+
+	return nil
+}
+
+// Search runs a subtree search. Transient failures are retried according to the retry policy, lost connections are
+// re-established before retrying.
+func (c *Client) Search(ctx context.Context, baseDN, filter string) (*ldap.SearchResult, error) {
+	return c.searchWithRetry(ctx, baseDN, filter, ldap.ScopeWholeSubtree)
+}
+
+// Lookup returns the entry with the given DN or nil if it does not exist.
+func (c *Client) Lookup(ctx context.Context, dn string) (*ldap.Entry, error) {
+	res, err := c.searchWithRetry(ctx, dn, "(objectClass=*)", ldap.ScopeBaseObject)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if len(res.Entries) == 0 {
+		return nil, nil
+	}
+
+	return res.Entries[0], nil
+}
+
+func (c *Client) searchWithRetry(ctx context.Context, baseDN, filter string, scope int) (*ldap.SearchResult, error) {
+	var res *ldap.SearchResult
+
+	err := retry.Do(ctx, retry.NewPolicy(c.config.Retry), "ldap", c.log.Logger, func() error {
+		if err := c.ensureConnected(ctx); err != nil {
+			return retry.Retryable(err, 0)
+		}
+
+		var err error
+
+		res, err = c.search(ctx, baseDN, filter, scope)
+		if err == nil {
+			return nil
+		}
+
+		if c.conn == nil || c.conn.IsClosing() || ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+			c.Close()
+
+			return retry.Retryable(err, 0)
+		}
+
+		if c.retryable(err) {
+			return retry.Retryable(err, 0)
+		}
+
+		return err
+	})
+
+	return res, err
+}
+
+func (c *Client) retryable(err error) bool {
+	var ldapErr *ldap.Error
+	if !errors.As(err, &ldapErr) {
+		return false
+	}
+
+	return slices.Contains(c.config.Retry.LDAPResultCodes, int(ldapErr.ResultCode))
+}
+
+// search runs a single search. As the ldap library does not support contexts, the connection is closed when ctx is
+// done, which aborts the pending request.
+func (c *Client) search(ctx context.Context, baseDN, filter string, scope int) (*ldap.SearchResult, error) {
+	type result struct {
+		res *ldap.SearchResult
+		err error
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	conn := c.conn
+	ch := make(chan result, 1)
+
+	go func() {
+		res, err := conn.Search(
+			ldap.NewSearchRequest(
+				baseDN,
+				scope, ldap.NeverDerefAliases, 0, 0, false,
+				filter,
+				[]string{},
+				nil,
+			),
+		)
+
+		ch <- result{res: res, err: err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.res, r.err
+	case <-ctx.Done():
+		c.Close()
+
+		return nil, errors.Wrap(ctx.Err(), "ldap search aborted")
+	}
+}
@@ -0,0 +1,454 @@
+package ldap_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+)
+
+// writeCert writes a self-signed certificate and its key to PEM files.
+func writeCert(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gitea-ldap-sync"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	certFile, keyFile := writeCert(t)
+
+	tests := []struct {
+		name           string
+		conf           config.LDAPConfig
+		addr           string
+		wantServerName string
+		wantMinVersion uint16
+		wantCerts      int
+		wantErr        bool
+	}{
+		{
+			name:           "Test if the server name is taken from the address",
+			addr:           "dc1.corp:636",
+			wantServerName: "dc1.corp",
+			wantMinVersion: tls.VersionTLS12,
+		},
+		{
+			name:           "Test if the configured server name and min version are used",
+			conf:           config.LDAPConfig{ServerName: "ldap.corp", TLSMinVersion: "1.3"},
+			addr:           "10.0.0.1:636",
+			wantServerName: "ldap.corp",
+			wantMinVersion: tls.VersionTLS13,
+		},
+		{
+			name:           "Test if the client certificate is loaded",
+			conf:           config.LDAPConfig{ClientCertFile: certFile, ClientKeyFile: keyFile},
+			addr:           "dc1.corp:636",
+			wantServerName: "dc1.corp",
+			wantMinVersion: tls.VersionTLS12,
+			wantCerts:      1,
+		},
+		{
+			name:    "Test if an invalid client certificate is rejected",
+			conf:    config.LDAPConfig{ClientCertFile: certFile, ClientKeyFile: certFile},
+			addr:    "dc1.corp:636",
+			wantErr: true,
+		},
+		{
+			name:    "Test if a ca file without certificates is rejected",
+			conf:    config.LDAPConfig{CAFile: keyFile},
+			addr:    "dc1.corp:636",
+			wantErr: true,
+		},
+		{
+			name:    "Test if an unknown min version is rejected",
+			conf:    config.LDAPConfig{TLSMinVersion: "2.0"},
+			addr:    "dc1.corp:636",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ldap.NewTLSConfig(&tt.conf, tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTLSConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.ServerName != tt.wantServerName {
+				t.Errorf("ServerName = %s, want %s", got.ServerName, tt.wantServerName)
+			}
+
+			if got.MinVersion != tt.wantMinVersion {
+				t.Errorf("MinVersion = %x, want %x", got.MinVersion, tt.wantMinVersion)
+			}
+
+			if len(got.Certificates) != tt.wantCerts {
+				t.Errorf("len(Certificates) = %d, want %d", len(got.Certificates), tt.wantCerts)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfigCAFile(t *testing.T) {
+	certFile, _ := writeCert(t)
+
+	got, err := ldap.NewTLSConfig(&config.LDAPConfig{CAFile: certFile}, "dc1.corp:636")
+	if err != nil {
+		t.Fatalf("NewTLSConfig() error = %v", err)
+	}
+
+	// The CA file is trusted in addition to the system CAs.
+	want, err := x509.SystemCertPool()
+	if err != nil {
+		want = x509.NewCertPool()
+	}
+
+	b, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want.AppendCertsFromPEM(b)
+
+	if !got.RootCAs.Equal(want) {
+		t.Error("RootCAs does not contain the system CAs and the CA file")
+	}
+}
+
+// fakeLDAP is a minimal LDAP server accepting every bind and answering every search with a single entry.
+type fakeLDAP struct {
+	listener net.Listener
+	// stall leaves the searches unanswered.
+	stall bool
+
+	mu       sync.Mutex
+	conns    []net.Conn
+	accepted int
+}
+
+func newFakeLDAP(t *testing.T, stall bool) *fakeLDAP {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeLDAP{listener: listener, stall: stall}
+	t.Cleanup(s.close)
+
+	go s.serve()
+
+	return s
+}
+
+func (s *fakeLDAP) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *fakeLDAP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.accepted++
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeLDAP) handle(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		var responses []*ber.Packet
+
+		switch packet.Children[1].Tag {
+		case goldap.ApplicationBindRequest:
+			responses = append(responses, result(packet, goldap.ApplicationBindResponse, goldap.LDAPResultSuccess))
+		case goldap.ApplicationSearchRequest:
+			if s.stall {
+				continue
+			}
+
+			responses = append(responses,
+				entry(packet, packet.Children[1].Children[0].Data.String()),
+				result(packet, goldap.ApplicationSearchResultDone, goldap.LDAPResultSuccess),
+			)
+		default:
+			return
+		}
+
+		for _, p := range responses {
+			if _, err := conn.Write(p.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// drop closes the open connections, eg.: the idle timeout of the server or a load balancer.
+func (s *fakeLDAP) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+
+	s.conns = nil
+}
+
+func (s *fakeLDAP) close() {
+	s.listener.Close()
+	s.drop()
+}
+
+func (s *fakeLDAP) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.accepted
+}
+
+// entry returns a search result entry with the given DN.
+func entry(request *ber.Packet, dn string) *ber.Packet {
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "Entry")
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "DN"))
+	res.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes"))
+
+	p := envelope(request)
+	p.AppendChild(res)
+
+	return p
+}
+
+// unreachableURL returns the URL of a closed port.
+func unreachableURL(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	return "ldap://" + listener.Addr().String()
+}
+
+// newConnConfig returns the configuration of a client connecting to the given LDAP URLs.
+func newConnConfig(urls ...string) *config.Config {
+	return &config.Config{
+		LDAP: &config.LDAPConfig{
+			URL:              strings.Join(urls, " "),
+			BindMethod:       config.BindMethodSimple,
+			ConnectTimeout:   time.Second,
+			OperationTimeout: 5 * time.Second,
+		},
+		Retry: &config.RetryConfig{MaxAttempts: 2},
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		unreachable     bool
+		drop            bool
+		call            func(ctx context.Context, c *ldap.Client) error
+		wantConnections int
+	}{
+		{
+			name:            "Test if the search reconnects after the connection is dropped",
+			drop:            true,
+			call:            search,
+			wantConnections: 2,
+		},
+		{
+			name:            "Test if the health check reconnects after the connection is dropped",
+			drop:            true,
+			call:            healthCheck,
+			wantConnections: 2,
+		},
+		{
+			name:            "Test if the health check keeps a working connection",
+			call:            healthCheck,
+			wantConnections: 1,
+		},
+		{
+			name:            "Test if the next server is used if the first one is unreachable",
+			unreachable:     true,
+			call:            search,
+			wantConnections: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newFakeLDAP(t, false)
+			ctx := context.Background()
+
+			urls := []string{server.url()}
+			if tt.unreachable {
+				urls = append([]string{unreachableURL(t)}, urls...)
+			}
+
+			c, err := ldap.New(ctx, newConnConfig(urls...))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			defer c.Close()
+
+			if tt.drop {
+				server.drop()
+			}
+
+			if err := tt.call(ctx, c); err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			if got := server.connections(); got != tt.wantConnections {
+				t.Errorf("connections = %d, want %d", got, tt.wantConnections)
+			}
+		})
+	}
+}
+
+func TestHealthCheckUnavailable(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeLDAP(t, false)
+
+	c, err := ldap.New(context.Background(), newConnConfig(server.url()))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	defer c.Close()
+
+	server.close()
+
+	err = c.HealthCheck(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failed to connect to any of the LDAP servers") {
+		t.Fatalf("HealthCheck() error = %v, want the servers to be unavailable", err)
+	}
+}
+
+func TestSearchCancel(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeLDAP(t, true)
+
+	c, err := ldap.New(context.Background(), newConnConfig(server.url()))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+
+	_, err = c.Search(ctx, "ou=users,dc=example,dc=com", "(objectClass=*)")
+	if err == nil || !strings.Contains(err.Error(), "ldap search aborted") {
+		t.Fatalf("Search() error = %v, want the search to be aborted", err)
+	}
+
+	// The operation timeout of the connection is 5 seconds.
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Search() returned after %s, want it to be aborted when the context is done", elapsed)
+	}
+}
+
+func healthCheck(ctx context.Context, c *ldap.Client) error {
+	return c.HealthCheck(ctx)
+}
+
+func search(ctx context.Context, c *ldap.Client) error {
+	res, err := c.Search(ctx, "ou=users,dc=example,dc=com", "(objectClass=*)")
+	if err != nil {
+		return err
+	}
+
+	if len(res.Entries) != 1 {
+		return errors.Errorf("entries = %d, want 1", len(res.Entries))
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package ldap
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/logger"
+)
+
+// Exported for the tests of the ldap_test package.
+var NewTLSConfig = newTLSConfig
+
+// Endpoints returns the LDAP servers of the configuration.
+func Endpoints(ctx context.Context, conf *config.Config) ([]Endpoint, error) {
+	c := &Client{config: conf, log: logger.New().Tag("ldap")}
+
+	return c.endpoints(ctx)
+}
+
+// SetResolveSRV replaces the DNS SRV lookup until the returned function is called.
+func SetResolveSRV(fn func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)) func() {
+	old := resolveSRV
+	resolveSRV = fn
+
+	return func() { resolveSRV = old }
+}
+
+var ConfigureBind = configureBind
+
+// OwnersGroups returns the owners groups of the organization groups found among the groups and the subgroups.
+func OwnersGroups(conf *config.Config, groups, subgroups []*ldap.Entry) (map[string]*ldap.Entry, error) {
+	c := &Client{config: conf, log: logger.New().Tag("ldap")}
+
+	return c.ownersGroups(context.Background(), groups, subgroups)
+}
+
+// BuildDirectory builds the directory of the groups, the subgroups and the users.
+func BuildDirectory(conf *config.Config, groups, subgroups, users []*ldap.Entry) (*Directory, error) {
+	templates, err := newTemplates(conf.LDAP)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{config: conf, templates: templates, log: logger.New().Tag("ldap")}
+
+	return c.buildDirectory(groups, subgroups, users, nil, nil, nil), nil
+}
@@ -1,12 +1,15 @@
 package ldap
 
 import (
-	"crypto/tls"
+	"context"
+	"encoding/hex"
 	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/go-ldap/ldap/v3"
 	"github.com/pkg/errors"
-	"gopkg.in/ldap.v3"
 
 	"github.com/janosmiko/gitea-ldap-sync/internal/config"
 	"github.com/janosmiko/gitea-ldap-sync/internal/logger"
@@ -15,15 +18,17 @@ import (
 )
 
 type Client struct {
-	ldap.Client
-	config *config.Config
-	log    logger.Logger
+	conn      *ldap.Conn
+	config    *config.Config
+	templates *templates
+	log       logger.Logger
 }
 
-func (c *Client) NewUser(entry *ldap.Entry, restricted, admin bool) *User {
+func (c *Client) NewUser(entry *ldap.Entry, name string, restricted, admin bool) *User {
 	return &User{
 		Entry:      entry,
-		Name:       entry.GetAttributeValue(c.config.LDAP.UserUsernameAttribute),
+		Name:       name,
+		ID:         c.userID(entry),
 		Restricted: ptr.To(restricted),
 		Admin:      ptr.To(admin),
 		config:     c.config.LDAP,
@@ -33,12 +38,71 @@ func (c *Client) NewUser(entry *ldap.Entry, restricted, admin bool) *User {
 type Directory struct {
 	Organizations Organizations
 	Users         Users
+	// Collisions are the LDAP entries skipped, because their Gitea names are already used by other entries.
+	Collisions []Collision
 }
 
-type Organization struct {
+// Collision is an LDAP entry whose Gitea name is already used by another entry.
+type Collision struct {
+	// Kind is the kind of the Gitea object: user, organization or team.
+	Kind string
+	// Name is the Gitea name, the teams are referenced as <organization>/<team>.
 	Name string
+	DN   string
+	// Other is the DN of the entry using the name.
+	Other string
+}
+
+// GroupUsers returns the users of a group referenced as <organization>/<team> or as <organization>. The users of an
+// organization are the users of all of its teams (including the owners). It reports whether the group exists.
+func (d *Directory) GroupUsers(name string) (Users, bool) {
+	orgName, teamName, isTeam := strings.Cut(name, "/")
+
+	org, ok := d.Organizations[orgName]
+	if !ok {
+		return nil, false
+	}
+
+	if isTeam {
+		if teamName == OwnersTeam && org.Owners != nil {
+			return org.Owners.Users, true
+		}
+
+		t, ok := org.Teams[teamName]
+		if !ok {
+			return nil, false
+		}
+
+		return t.Users, true
+	}
+
+	users := make(Users)
+
+	for _, t := range org.Teams {
+		for name, u := range t.Users {
+			users[name] = u
+		}
+	}
+
+	if org.Owners != nil {
+		for name, u := range org.Owners.Users {
+			users[name] = u
+		}
+	}
+
+	return users, true
+}
+
+type Organization struct {
+	Name        string
+	FullName    string
+	Description string
 	*ldap.Entry
 	Teams map[string]*Team
+	// Owners are the members of the owners group of the organization, nil if it has no owners group.
+	Owners *Team
+	// LDAPName is the name in LDAP (the value of the name attribute or the name template) before the sanitization.
+	LDAPName string
 }
 
 type Organizations map[string]*Organization
@@ -52,10 +116,16 @@ func (c *Organizations) String() string {
 	return strings.Join(s, ",")
 }
 
+// OwnersTeam is the name of the team of the organization owners in Gitea.
+const OwnersTeam = "Owners"
+
 type Team struct {
-	Name string
+	Name        string
+	Description string
 	*ldap.Entry
 	Users map[string]*User
+	// LDAPName is the name in LDAP (the value of the name attribute or the name template) before the sanitization.
+	LDAPName string
 }
 
 type User struct {
@@ -64,10 +134,26 @@ type User struct {
 	Restricted *bool
 	Admin      *bool
 	config     *config.LDAPConfig
+	// ID is the value of the immutable user ID attribute, empty if it's not configured.
+	ID string
 }
 
 type Users map[string]*User
 
+// userID returns the immutable ID of the user. The binary IDs (eg.: objectGUID) are hex encoded.
+func (c *Client) userID(entry *ldap.Entry) string {
+	if c.config.LDAP.UserIDAttribute == "" {
+		return ""
+	}
+
+	raw := entry.GetRawAttributeValue(c.config.LDAP.UserIDAttribute)
+	if utf8.Valid(raw) && strings.IndexFunc(string(raw), func(r rune) bool { return !unicode.IsPrint(r) }) < 0 {
+		return string(raw)
+	}
+
+	return hex.EncodeToString(raw)
+}
+
 func (u *User) Fullname(c *config.LDAPConfig) string {
 	firstname := u.GetAttributeValue(c.UserFirstNameAttribute)
 	surname := u.GetAttributeValue(c.UserSurnameAttribute)
@@ -82,98 +168,46 @@ func (u *User) Fullname(c *config.LDAPConfig) string {
 	return fullname
 }
 
-func (c *Client) Close() {
-	c.Client.Close()
-}
-
-func New(conf *config.Config) (*Client, error) {
-	l, err := NewLDAPConn(conf)
-	if err != nil {
-		return nil, err
-	}
-
-	ldapClient := &Client{
-		Client: l,
-		config: conf,
-		log:    logger.New().Tag("ldap"),
-	}
+func (c *Client) GetDirectory(ctx context.Context) (*Directory, error) {
+	c.log.Debug().Msg("Getting ldap directory")
 
-	if err := ldapClient.configureBind(); err != nil {
+	if err := c.HealthCheck(ctx); err != nil {
 		return nil, err
 	}
 
-	return ldapClient, nil
-}
-
-func NewLDAPConn(c *config.Config) (*ldap.Conn, error) {
-	// Prepare LDAPConfig Connection
-	if c.LDAP.UseTLS {
-		//nolint:gosec // allowInsecureTLS should be used with caution.
-		l, err := ldap.DialTLS(
-			"tcp",
-			fmt.Sprintf("%s:%d", c.LDAP.URL, c.LDAP.Port),
-			&tls.Config{InsecureSkipVerify: c.LDAP.AllowInsecureTLS},
-		)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to dial TLS: %s:%d", c.LDAP.URL, c.LDAP.Port)
-		}
-
-		return l, nil
-	}
-
-	l, err := ldap.Dial("tcp", fmt.Sprintf("%s:%d", c.LDAP.URL, c.LDAP.Port))
+	ldapGroups, err := c.groups(ctx)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to dial non-TLS: %s:%d", c.LDAP.URL, c.LDAP.Port)
-	}
-
-	return l, nil
-}
-
-func (c *Client) configureBind() error {
-	if len(c.config.LDAP.BindDN) == 0 {
-		if err := c.UnauthenticatedBind(""); err != nil {
-			return errors.Wrap(err, "failed to set unauthenticated bind")
-		}
-	}
-
-	if err := c.Bind(c.config.LDAP.BindDN, c.config.LDAP.BindPassword); err != nil {
-		return errors.Wrapf(err, "failed to bind with binddn: %s", c.config.LDAP.BindDN)
+		return nil, err
 	}
 
-	return nil
-}
-
-func (c *Client) GetDirectory() (*Directory, error) {
-	c.log.Debug().Msg("Getting ldap directory")
-
-	ldapGroups, err := c.groups()
+	ldapTeams, err := c.teams(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	ldapTeams, err := c.teams()
+	ldapUsers, err := c.getUsers(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	ldapUsers, err := c.getUsers()
+	ldapAdminUsers, err := c.adminUsers(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	ldapAdminUsers, err := c.adminUsers()
+	ldapRestrictedUsers, err := c.restrictedUsers(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	ldapRestrictedUsers, err := c.restrictedUsers()
+	owners, err := c.ownersGroups(ctx, ldapGroups, ldapTeams)
 	if err != nil {
 		return nil, err
 	}
 
 	c.log.Info().Msg("LDAP directory fetched")
 
-	dir := c.buildDirectory(ldapGroups, ldapTeams, ldapUsers, ldapRestrictedUsers, ldapAdminUsers)
+	dir := c.buildDirectory(ldapGroups, ldapTeams, ldapUsers, ldapRestrictedUsers, ldapAdminUsers, owners)
 
 	return dir, nil
 }
@@ -181,6 +215,7 @@ func (c *Client) GetDirectory() (*Directory, error) {
 func (c *Client) buildDirectory(
 	ldapGroups []*ldap.Entry, ldapTeams []*ldap.Entry,
 	ldapUsers []*ldap.Entry, ldapAdminUsers []*ldap.Entry, ldapRestrictedUsers []*ldap.Entry,
+	owners map[string]*ldap.Entry,
 ) *Directory {
 	c.log.Debug().Msg("Building ldap directory")
 
@@ -191,8 +226,10 @@ func (c *Client) buildDirectory(
 		Users:         u,
 	}
 
-	c.buildGroups(dir, ldapGroups, ldapTeams, ldapUsers)
-	c.buildUsers(dir, ldapUsers, ldapRestrictedUsers, ldapAdminUsers)
+	users := c.userNames(dir, ldapUsers)
+
+	c.buildGroups(dir, ldapGroups, ldapTeams, users, owners)
+	c.buildUsers(dir, ldapUsers, users, ldapRestrictedUsers, ldapAdminUsers)
 
 	c.log.Info().Msg("LDAP directory built")
 
@@ -200,57 +237,164 @@ func (c *Client) buildDirectory(
 }
 
 func (c *Client) buildGroups(
-	dir *Directory, ldapGroups []*ldap.Entry, ldapTeams []*ldap.Entry, ldapUsers []*ldap.Entry,
+	dir *Directory, ldapGroups []*ldap.Entry, ldapTeams []*ldap.Entry, users map[string]*User,
+	owners map[string]*ldap.Entry,
 ) {
 	c.log.Debug().Msg("Building ldap groups")
 
-	ldapOrgs := difference(ldapGroups, ldapTeams)
+	// The owners groups are neither organizations nor teams.
+	ownersDNs := make(map[string]struct{}, len(owners))
+	for _, g := range owners {
+		ownersDNs[strings.ToLower(g.DN)] = struct{}{}
+	}
 
-	for _, o := range ldapOrgs {
-		teams := make(map[string]*Team)
+	for _, o := range c.orgCandidates(dir, difference(ldapGroups, ldapTeams), ownersDNs) {
+		org := &Organization{
+			Name:        o.name,
+			LDAPName:    o.raw,
+			FullName:    o.fullName,
+			Description: o.description,
+			Entry:       o.entry,
+			Teams:       make(map[string]*Team),
+		}
+		dir.Organizations[o.name] = org
 
-		dir.Organizations[o.GetAttributeValue(c.config.LDAP.GroupNameAttribute)] = &Organization{
-			Name:  o.GetAttributeValue(c.config.LDAP.GroupNameAttribute),
-			Entry: o,
-			Teams: teams,
+		if g, ok := owners[strings.ToLower(o.entry.DN)]; ok {
+			org.Owners = &Team{
+				Name:  OwnersTeam,
+				Entry: g,
+				Users: c.members(g, users),
+			}
 		}
 
-		for _, t := range ldapTeams {
-			memberOf := t.GetAttributeValue("memberOf")
-			if strings.EqualFold(memberOf, o.DN) {
-				users := make(map[string]*User)
-
-				for _, u := range t.GetAttributeValues("member") {
-					for _, v := range ldapUsers {
-						if strings.EqualFold(u, v.DN) {
-							users[v.GetAttributeValue(c.config.LDAP.UserUsernameAttribute)] = c.NewUser(v, false, false)
-						}
-					}
-				}
+		for _, t := range c.teamCandidates(dir, org, ldapTeams, ownersDNs) {
+			org.Teams[t.name] = &Team{
+				Name:        t.name,
+				LDAPName:    t.raw,
+				Description: t.description,
+				Entry:       t.entry,
+				Users:       c.members(t.entry, users),
+			}
+		}
+
+		c.buildDefaultTeam(org, users)
+	}
+}
 
-				name := t.GetAttributeValue(c.config.LDAP.SubgroupNameAttribute)
+// buildDefaultTeam adds the default team to the organization. Its members are the users who are direct members of the
+// organization group, its description is the description of the organization.
+func (c *Client) buildDefaultTeam(org *Organization, users map[string]*User) {
+	name := c.config.LDAP.DefaultTeam
+	if name == "" {
+		return
+	}
 
-				if c.config.LDAP.TrimParentName {
-					separator := c.config.LDAP.SubgroupSeparator
-					name = name[strings.Index(name, separator)+len(separator):]
-				}
+	for teamName := range org.Teams {
+		if strings.EqualFold(teamName, name) {
+			c.log.Warn().Msgf(
+				"Default team is not created, a subgroup has the same name: %s (organization: %s)", name, org.Name,
+			)
+
+			return
+		}
+	}
 
-				dir.Organizations[o.GetAttributeValue(c.config.LDAP.GroupNameAttribute)].Teams[name] = &Team{
-					Name:  name,
-					Entry: t,
-					Users: users,
+	// The subgroup description attribute and template are meant for the subgroup entries, the default team uses the
+	// description of the organization group.
+	org.Teams[name] = &Team{
+		Name:        name,
+		LDAPName:    name,
+		Description: org.Description,
+		Entry:       org.Entry,
+		Users:       c.members(org.Entry, users),
+	}
+}
+
+// members returns the users who are members of the group. The users are keyed by their lowercase DN.
+func (c *Client) members(group *ldap.Entry, users map[string]*User) map[string]*User {
+	members := make(map[string]*User)
+
+	for _, dn := range group.GetAttributeValues("member") {
+		if u, ok := users[strings.ToLower(dn)]; ok {
+			members[u.Name] = c.NewUser(u.Entry, u.Name, false, false)
+		}
+	}
+
+	return members
+}
+
+// ownersGroups finds the owners group of the organization groups. The owners group is referenced by the DN in the
+// owners group attribute of the organization group or it's named <organization><owners group suffix>. The result is
+// keyed by the lowercase DN of the organization group.
+func (c *Client) ownersGroups(
+	ctx context.Context, ldapGroups []*ldap.Entry, ldapTeams []*ldap.Entry,
+) (map[string]*ldap.Entry, error) {
+	owners := make(map[string]*ldap.Entry)
+
+	attr, suffix := c.config.LDAP.OwnersGroupAttribute, c.config.LDAP.OwnersGroupSuffix
+	if attr == "" && suffix == "" {
+		return owners, nil
+	}
+
+	byDN := make(map[string]*ldap.Entry, len(ldapGroups)+len(ldapTeams))
+	byName := make(map[string]*ldap.Entry, len(ldapGroups)+len(ldapTeams))
+
+	for _, g := range ldapGroups {
+		byDN[strings.ToLower(g.DN)] = g
+		byName[g.GetAttributeValue(c.config.LDAP.GroupNameAttribute)] = g
+	}
+
+	for _, t := range ldapTeams {
+		byDN[strings.ToLower(t.DN)] = t
+		byName[t.GetAttributeValue(c.config.LDAP.SubgroupNameAttribute)] = t
+	}
+
+	for _, o := range ldapGroups {
+		name := o.GetAttributeValue(c.config.LDAP.GroupNameAttribute)
+
+		if dn := o.GetAttributeValue(attr); attr != "" && dn != "" {
+			g, ok := byDN[strings.ToLower(dn)]
+			if !ok {
+				var err error
+
+				if g, err = c.Lookup(ctx, dn); err != nil {
+					return nil, errors.Wrapf(err, "looking up owners group of %s: %s", name, dn)
 				}
 			}
+
+			if g == nil {
+				c.log.Warn().Msgf("Owners group of %s does not exist: %s", name, dn)
+
+				continue
+			}
+
+			owners[strings.ToLower(o.DN)] = g
+
+			continue
+		}
+
+		if g, ok := byName[name+suffix]; suffix != "" && ok {
+			owners[strings.ToLower(o.DN)] = g
 		}
 	}
+
+	c.log.Info().Msgf("Found %d owners groups in ldap", len(owners))
+
+	return owners, nil
 }
 
 func (c *Client) buildUsers(
-	dir *Directory, ldapUsers []*ldap.Entry, ldapRestrictedUsers []*ldap.Entry, ldapAdminUsers []*ldap.Entry,
+	dir *Directory, ldapUsers []*ldap.Entry, users map[string]*User,
+	ldapRestrictedUsers []*ldap.Entry, ldapAdminUsers []*ldap.Entry,
 ) {
 	c.log.Debug().Msg("Building ldap users")
 
 	for _, u := range ldapUsers {
+		user, ok := users[strings.ToLower(u.DN)]
+		if !ok {
+			continue
+		}
+
 		restricted := false
 		admin := false
 
@@ -277,14 +421,14 @@ func (c *Client) buildUsers(
 			}
 		}
 
-		dir.Users[u.GetAttributeValue(c.config.LDAP.UserUsernameAttribute)] = c.NewUser(u, restricted, admin)
+		dir.Users[user.Name] = c.NewUser(u, user.Name, restricted, admin)
 	}
 }
 
-func (c *Client) groups() ([]*ldap.Entry, error) {
+func (c *Client) groups(ctx context.Context) ([]*ldap.Entry, error) {
 	c.log.Debug().Msg("Searching for groups in ldap")
 
-	searchResult, err := c.Search(c.config.LDAP.GroupSearchBase, c.config.LDAP.GroupFilter)
+	searchResult, err := c.Search(ctx, c.config.LDAP.GroupSearchBase, c.config.LDAP.GroupFilter)
 	if err != nil {
 		return nil, errors.Wrapf(
 			err, "failed to search for groups in ldap. searchbase: %s, filter: %s",
@@ -297,10 +441,10 @@ func (c *Client) groups() ([]*ldap.Entry, error) {
 	return searchResult.Entries, nil
 }
 
-func (c *Client) teams() ([]*ldap.Entry, error) {
+func (c *Client) teams(ctx context.Context) ([]*ldap.Entry, error) {
 	c.log.Debug().Msg("Searching for teams in ldap")
 
-	searchResult, err := c.Search(c.config.LDAP.SubgroupSearchBase, c.config.LDAP.SubgroupFilter)
+	searchResult, err := c.Search(ctx, c.config.LDAP.SubgroupSearchBase, c.config.LDAP.SubgroupFilter)
 	if err != nil {
 		return nil, errors.Wrapf(
 			err, "failed to search for teams in ldap. searchbase: %s, filter: %s",
@@ -313,14 +457,14 @@ func (c *Client) teams() ([]*ldap.Entry, error) {
 	return searchResult.Entries, nil
 }
 
-func (c *Client) restrictedUsers() ([]*ldap.Entry, error) {
+func (c *Client) restrictedUsers(ctx context.Context) ([]*ldap.Entry, error) {
 	if len(c.config.LDAP.RestrictedFilter) == 0 {
 		return nil, nil
 	}
 
 	c.log.Debug().Msg("Searching for restricted users in ldap")
 
-	searchResult, err := c.Search(c.config.LDAP.UserSearchBase, c.config.LDAP.RestrictedFilter)
+	searchResult, err := c.Search(ctx, c.config.LDAP.UserSearchBase, c.config.LDAP.RestrictedFilter)
 	if err != nil {
 		return nil, errors.Wrapf(
 			err, "failed to search for restricted users in ldap. searchbase: %s, filter: %s",
@@ -333,14 +477,14 @@ func (c *Client) restrictedUsers() ([]*ldap.Entry, error) {
 	return searchResult.Entries, nil
 }
 
-func (c *Client) adminUsers() ([]*ldap.Entry, error) {
+func (c *Client) adminUsers(ctx context.Context) ([]*ldap.Entry, error) {
 	if len(c.config.LDAP.AdminFilter) == 0 {
 		return nil, nil
 	}
 
 	c.log.Debug().Msg("Searching for admin users in ldap")
 
-	searchResult, err := c.Search(c.config.LDAP.UserSearchBase, c.config.LDAP.AdminFilter)
+	searchResult, err := c.Search(ctx, c.config.LDAP.UserSearchBase, c.config.LDAP.AdminFilter)
 	if err != nil {
 		return nil, errors.Wrapf(
 			err, "failed to search for admin users in ldap. searchbase: %s, filter: %s",
@@ -353,10 +497,10 @@ func (c *Client) adminUsers() ([]*ldap.Entry, error) {
 	return searchResult.Entries, nil
 }
 
-func (c *Client) getUsers() ([]*ldap.Entry, error) {
+func (c *Client) getUsers(ctx context.Context) ([]*ldap.Entry, error) {
 	c.log.Debug().Msg("Searching for users in ldap")
 
-	searchResult, err := c.Search(c.config.LDAP.UserSearchBase, c.config.LDAP.UserFilter)
+	searchResult, err := c.Search(ctx, c.config.LDAP.UserSearchBase, c.config.LDAP.UserFilter)
 	if err != nil {
 		return nil, errors.Wrapf(
 			err, "failed to search for users in ldap. searchbase: %s, filter: %s", c.config.LDAP.UserSearchBase,
@@ -385,15 +529,3 @@ func difference(a, b []*ldap.Entry) []*ldap.Entry {
 
 	return diff
 }
-
-func (c *Client) Search(baseDN, filter string) (*ldap.SearchResult, error) {
-	return c.Client.Search(
-		ldap.NewSearchRequest(
-			baseDN,
-			ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-			filter,
-			[]string{},
-			nil,
-		),
-	)
-}
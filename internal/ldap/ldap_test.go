@@ -0,0 +1,212 @@
+package ldap_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	goldap "github.com/go-ldap/ldap/v3"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+)
+
+// group returns a group entry with the given cn under ou=groups.
+func group(cn string, attrs map[string][]string) *goldap.Entry {
+	if attrs == nil {
+		attrs = make(map[string][]string)
+	}
+
+	attrs["cn"] = []string{cn}
+
+	return goldap.NewEntry("cn="+cn+",ou=groups,dc=example,dc=com", attrs)
+}
+
+func TestOwnersGroups(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		attribute string
+		suffix    string
+		groups    []*goldap.Entry
+		subgroups []*goldap.Entry
+		// want maps the organization groups to their owners groups by cn.
+		want map[string]string
+	}{
+		{
+			name:      "Test if the owners group is referenced by the dn attribute",
+			attribute: "owners",
+			groups: []*goldap.Entry{
+				group("developers", map[string][]string{"owners": {"CN=Dev-Leads,OU=Groups,DC=example,DC=com"}}),
+				group("dev-leads", nil),
+			},
+			want: map[string]string{"developers": "dev-leads"},
+		},
+		{
+			name:      "Test if the owners group referenced by the dn attribute can be a subgroup",
+			attribute: "owners",
+			groups: []*goldap.Entry{
+				group("developers", map[string][]string{"owners": {"cn=dev-leads,ou=groups,dc=example,dc=com"}}),
+			},
+			subgroups: []*goldap.Entry{group("dev-leads", nil)},
+			want:      map[string]string{"developers": "dev-leads"},
+		},
+		{
+			name:   "Test if the owners group is found by the suffix",
+			suffix: "-owners",
+			groups: []*goldap.Entry{
+				group("developers", nil),
+				group("developers-owners", nil),
+				group("testers", nil),
+			},
+			want: map[string]string{"developers": "developers-owners"},
+		},
+		{
+			name:      "Test if the dn attribute takes precedence over the suffix",
+			attribute: "owners",
+			suffix:    "-owners",
+			groups: []*goldap.Entry{
+				group("developers", map[string][]string{"owners": {"cn=dev-leads,ou=groups,dc=example,dc=com"}}),
+				group("developers-owners", nil),
+				group("dev-leads", nil),
+				group("testers", nil),
+				group("testers-owners", nil),
+			},
+			want: map[string]string{"developers": "dev-leads", "testers": "testers-owners"},
+		},
+		{
+			name:   "Test if no owners groups are resolved if neither the attribute nor the suffix is set",
+			groups: []*goldap.Entry{group("developers", nil), group("developers-owners", nil)},
+			want:   map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			conf := &config.Config{LDAP: &config.LDAPConfig{
+				GroupNameAttribute:    "cn",
+				SubgroupNameAttribute: "cn",
+				OwnersGroupAttribute:  tt.attribute,
+				OwnersGroupSuffix:     tt.suffix,
+			}}
+
+			owners, err := ldap.OwnersGroups(conf, tt.groups, tt.subgroups)
+			if err != nil {
+				t.Fatalf("OwnersGroups() error = %v", err)
+			}
+
+			got := make(map[string]string, len(owners))
+
+			for _, g := range tt.groups {
+				if o, ok := owners["cn="+g.GetAttributeValue("cn")+",ou=groups,dc=example,dc=com"]; ok {
+					got[g.GetAttributeValue("cn")] = o.GetAttributeValue("cn")
+				}
+			}
+
+			if len(got) != len(owners) {
+				t.Errorf("OwnersGroups() = %d groups, want the groups keyed by their lowercase dn", len(owners))
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OwnersGroups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildDirectoryDefaultTeam(t *testing.T) {
+	t.Parallel()
+
+	const (
+		orgDN  = "cn=developers,ou=groups,dc=example,dc=com"
+		teamDN = "cn=backend,ou=groups,dc=example,dc=com"
+	)
+
+	user := func(name string) *goldap.Entry {
+		return goldap.NewEntry("uid="+name+",ou=users,dc=example,dc=com", map[string][]string{"uid": {name}})
+	}
+
+	users := []*goldap.Entry{user("alice"), user("bob"), user("carol")}
+	org := goldap.NewEntry(orgDN, map[string][]string{
+		"cn":          {"developers"},
+		"description": {"Developers"},
+		"member":      {"uid=alice,ou=users,dc=example,dc=com", teamDN, "UID=Carol,OU=Users,DC=example,DC=com"},
+	})
+	team := goldap.NewEntry(teamDN, map[string][]string{
+		"cn":       {"backend"},
+		"info":     {"Backend developers"},
+		"memberOf": {orgDN},
+		"member":   {"uid=bob,ou=users,dc=example,dc=com"},
+	})
+
+	conf := &config.Config{LDAP: &config.LDAPConfig{
+		UserUsernameAttribute:        "uid",
+		GroupNameAttribute:           "cn",
+		GroupDescriptionAttribute:    "description",
+		SubgroupNameAttribute:        "cn",
+		SubgroupDescriptionAttribute: "info",
+		DefaultTeam:                  "members",
+	}}
+
+	dir, err := ldap.BuildDirectory(conf, []*goldap.Entry{org, team}, []*goldap.Entry{team}, users)
+	if err != nil {
+		t.Fatalf("BuildDirectory() error = %v", err)
+	}
+
+	members, ok := dir.Organizations["developers"].Teams["members"]
+	if !ok {
+		t.Fatalf("BuildDirectory() teams = %v, want the default team", dir.Organizations["developers"].Teams)
+	}
+
+	var got []string
+	for name := range members.Users {
+		got = append(got, name)
+	}
+
+	sort.Strings(got)
+
+	// The members of the subgroups are not direct members of the organization.
+	if want := []string{"alice", "carol"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("default team members = %v, want %v", got, want)
+	}
+
+	if want := "Developers"; members.Description != want {
+		t.Errorf("default team description = %q, want %q", members.Description, want)
+	}
+}
+
+func TestBuildDirectoryReservedTeamName(t *testing.T) {
+	t.Parallel()
+
+	const orgDN = "cn=developers,ou=groups,dc=example,dc=com"
+
+	org := group("developers", nil)
+	subgroups := []*goldap.Entry{
+		group("owners", map[string][]string{"memberOf": {orgDN}}),
+		group("OWNERS!", map[string][]string{"memberOf": {orgDN}}),
+		group("backend", map[string][]string{"memberOf": {orgDN}}),
+	}
+
+	conf := &config.Config{LDAP: &config.LDAPConfig{
+		UserUsernameAttribute: "uid",
+		GroupNameAttribute:    "cn",
+		SubgroupNameAttribute: "cn",
+	}}
+
+	dir, err := ldap.BuildDirectory(conf, append([]*goldap.Entry{org}, subgroups...), subgroups, nil)
+	if err != nil {
+		t.Fatalf("BuildDirectory() error = %v", err)
+	}
+
+	var got []string
+	for name := range dir.Organizations["developers"].Teams {
+		got = append(got, name)
+	}
+
+	// The Owners team is synced from the owners group, the subgroups can't use its name.
+	if want := []string{"backend"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("teams = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,238 @@
+package ldap
+
+import (
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/naming"
+)
+
+// templates are the parsed naming templates, nil if the template is not configured.
+type templates struct {
+	groupName           *template.Template
+	groupFullName       *template.Template
+	groupDescription    *template.Template
+	subgroupName        *template.Template
+	subgroupDescription *template.Template
+}
+
+func newTemplates(c *config.LDAPConfig) (*templates, error) {
+	t := &templates{}
+
+	for _, v := range []struct {
+		tpl  **template.Template
+		name string
+		text string
+	}{
+		{&t.groupName, "ldap.group_name_template", c.GroupNameTemplate},
+		{&t.groupFullName, "ldap.group_fullname_template", c.GroupFullNameTemplate},
+		{&t.groupDescription, "ldap.group_description_template", c.GroupDescriptionTemplate},
+		{&t.subgroupName, "ldap.subgroup_name_template", c.SubgroupNameTemplate},
+		{&t.subgroupDescription, "ldap.subgroup_description_template", c.SubgroupDescriptionTemplate},
+	} {
+		tpl, err := naming.Parse(v.name, v.text)
+		if err != nil {
+			return nil, err
+		}
+
+		*v.tpl = tpl
+	}
+
+	return t, nil
+}
+
+// render renders the template for the entry. Without a template the value of the attribute is returned.
+func (c *Client) render(tpl *template.Template, attr string, entry *ldap.Entry, parent string) (string, error) {
+	if tpl == nil {
+		return entry.GetAttributeValue(attr), nil
+	}
+
+	return naming.Render(tpl, naming.NewData(entry, parent))
+}
+
+// orgNames returns the name, the full name and the description of the organization of the group.
+func (c *Client) orgNames(entry *ldap.Entry) (string, string, string, error) {
+	name, err := c.render(c.templates.groupName, c.config.LDAP.GroupNameAttribute, entry, "")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	fullName, err := c.render(c.templates.groupFullName, c.config.LDAP.GroupFullNameAttribute, entry, "")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	description, err := c.render(c.templates.groupDescription, c.config.LDAP.GroupDescriptionAttribute, entry, "")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return name, fullName, description, nil
+}
+
+// teamNames returns the name and the description of the team of the subgroup. If no name template is configured, the
+// parent name is trimmed from the name if trim_parent_name is enabled.
+func (c *Client) teamNames(entry *ldap.Entry, org string) (string, string, error) {
+	name, err := c.render(c.templates.subgroupName, c.config.LDAP.SubgroupNameAttribute, entry, org)
+	if err != nil {
+		return "", "", err
+	}
+
+	if c.templates.subgroupName == nil && c.config.LDAP.TrimParentName {
+		separator := c.config.LDAP.SubgroupSeparator
+		name = name[strings.Index(name, separator)+len(separator):]
+	}
+
+	description, err := c.render(
+		c.templates.subgroupDescription, c.config.LDAP.SubgroupDescriptionAttribute, entry, org,
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	return name, description, nil
+}
+
+// named is an LDAP entry with its Gitea name.
+type named struct {
+	entry *ldap.Entry
+	// raw is the name before the sanitization.
+	raw         string
+	name        string
+	fullName    string
+	description string
+}
+
+// claimNames returns the entries which keep their names. If multiple entries have the same name (case-insensitively),
+// the entry whose name needed no sanitization wins, then the one with the lowest DN, so the same entry wins on every
+// run. The other entries are recorded as collisions.
+func (d *Directory) claimNames(kind, prefix string, candidates []named) []named {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		exactI, exactJ := candidates[i].raw == candidates[i].name, candidates[j].raw == candidates[j].name
+		if exactI != exactJ {
+			return exactI
+		}
+
+		return strings.ToLower(candidates[i].entry.DN) < strings.ToLower(candidates[j].entry.DN)
+	})
+
+	claimed := make(map[string]*ldap.Entry, len(candidates))
+	result := make([]named, 0, len(candidates))
+
+	for _, n := range candidates {
+		key := strings.ToLower(n.name)
+
+		if other, ok := claimed[key]; ok {
+			d.Collisions = append(d.Collisions, Collision{Kind: kind, Name: prefix + n.name, DN: n.entry.DN, Other: other.DN})
+
+			continue
+		}
+
+		claimed[key] = n.entry
+		result = append(result, n)
+	}
+
+	return result
+}
+
+// userNames returns the users with valid Gitea names keyed by their lowercase DN.
+func (c *Client) userNames(dir *Directory, ldapUsers []*ldap.Entry) map[string]*User {
+	candidates := make([]named, 0, len(ldapUsers))
+
+	for _, u := range ldapUsers {
+		raw := u.GetAttributeValue(c.config.LDAP.UserUsernameAttribute)
+
+		name, err := naming.UserName(raw)
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("User skipped (reason: invalid name): %s", u.DN)
+
+			continue
+		}
+
+		candidates = append(candidates, named{entry: u, raw: raw, name: name})
+	}
+
+	users := make(map[string]*User, len(candidates))
+	for _, n := range dir.claimNames("user", "", candidates) {
+		users[strings.ToLower(n.entry.DN)] = c.NewUser(n.entry, n.name, false, false)
+	}
+
+	return users
+}
+
+// orgCandidates returns the organization groups with valid Gitea names.
+func (c *Client) orgCandidates(dir *Directory, ldapOrgs []*ldap.Entry, ownersDNs map[string]struct{}) []named {
+	candidates := make([]named, 0, len(ldapOrgs))
+
+	for _, o := range ldapOrgs {
+		if _, ok := ownersDNs[strings.ToLower(o.DN)]; ok {
+			continue
+		}
+
+		raw, fullName, description, err := c.orgNames(o)
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("Group skipped (reason: invalid name): %s", o.DN)
+
+			continue
+		}
+
+		name, err := naming.UserName(raw)
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("Group skipped (reason: invalid name): %s", o.DN)
+
+			continue
+		}
+
+		candidates = append(candidates, named{
+			entry: o, raw: raw, name: name, fullName: fullName, description: description,
+		})
+	}
+
+	return dir.claimNames("organization", "", candidates)
+}
+
+// teamCandidates returns the subgroups of the organization with valid Gitea names.
+func (c *Client) teamCandidates(
+	dir *Directory, org *Organization, ldapTeams []*ldap.Entry, ownersDNs map[string]struct{},
+) []named {
+	var candidates []named
+
+	for _, t := range ldapTeams {
+		if _, ok := ownersDNs[strings.ToLower(t.DN)]; ok {
+			continue
+		}
+
+		if !strings.EqualFold(t.GetAttributeValue("memberOf"), org.DN) {
+			continue
+		}
+
+		raw, description, err := c.teamNames(t, org.Name)
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("Subgroup skipped (reason: invalid name): %s", t.DN)
+
+			continue
+		}
+
+		name, err := naming.TeamName(raw)
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("Subgroup skipped (reason: invalid name): %s", t.DN)
+
+			continue
+		}
+
+		// The Owners team is synced from the owners group of the organization.
+		if strings.EqualFold(name, OwnersTeam) {
+			c.log.Warn().Msgf("Subgroup skipped (reason: reserved name): %s (team: %s/%s)", t.DN, org.Name, name)
+
+			continue
+		}
+
+		candidates = append(candidates, named{entry: t, raw: raw, name: name, description: description})
+	}
+
+	return dir.claimNames("team", org.Name+"/", candidates)
+}
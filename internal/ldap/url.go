@@ -0,0 +1,143 @@
+package ldap
+
+import (
+	"context"
+	"net"
+	urlpkg "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+)
+
+const (
+	defaultLDAPPort  = 389
+	defaultLDAPSPort = 636
+)
+
+// Endpoint is a single LDAP server address with the TLS mode used to connect to it.
+type Endpoint struct {
+	Addr    string
+	TLSMode string
+}
+
+// ParseURLs parses the configured LDAP URLs (separated by whitespace or comma). The scheme of an ldap:// or
+// ldaps:// URL determines the TLS mode (ldap:// can still be upgraded using StartTLS) and the default port. Bare
+// hostnames use the configured port and TLS mode.
+func ParseURLs(c *config.LDAPConfig) ([]Endpoint, error) {
+	fields := strings.FieldsFunc(c.URL, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	endpoints := make([]Endpoint, 0, len(fields))
+
+	for _, field := range fields {
+		if !strings.Contains(field, "://") {
+			endpoints = append(endpoints, Endpoint{
+				Addr:    net.JoinHostPort(field, strconv.Itoa(c.Port)),
+				TLSMode: c.GetTLSMode(),
+			})
+
+			continue
+		}
+
+		u, err := urlpkg.Parse(field)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing ldap url: %s", field)
+		}
+
+		var (
+			mode string
+			port int
+		)
+
+		switch strings.ToLower(u.Scheme) {
+		case "ldap":
+			mode, port = config.TLSModeNone, defaultLDAPPort
+			if c.GetTLSMode() == config.TLSModeStartTLS {
+				mode = config.TLSModeStartTLS
+			}
+		case "ldaps":
+			mode, port = config.TLSModeLDAPS, defaultLDAPSPort
+		default:
+			return nil, errors.Errorf("unsupported ldap url scheme: %s", field)
+		}
+
+		if u.Hostname() == "" {
+			return nil, errors.Errorf("missing host in ldap url: %s", field)
+		}
+
+		if u.Port() != "" {
+			if port, err = strconv.Atoi(u.Port()); err != nil {
+				return nil, errors.Wrapf(err, "parsing port of ldap url: %s", field)
+			}
+		}
+
+		endpoints = append(endpoints, Endpoint{
+			Addr:    net.JoinHostPort(u.Hostname(), strconv.Itoa(port)),
+			TLSMode: mode,
+		})
+	}
+
+	return endpoints, nil
+}
+
+// resolveSRV looks up the DNS SRV records, it's replaced in the tests.
+//
+//nolint:gochecknoglobals
+var resolveSRV = net.DefaultResolver.LookupSRV
+
+// lookupSRV discovers the LDAP servers of a domain (eg.: Active Directory domain controllers) using the
+// _ldap._tcp.<domain> DNS SRV record. The servers are returned ordered by priority and weight. The _ldap._tcp
+// servers speak plain LDAP, so the ldaps TLS mode is replaced by StartTLS for them.
+func lookupSRV(ctx context.Context, c *config.LDAPConfig) ([]Endpoint, error) {
+	_, records, err := resolveSRV(ctx, "ldap", "tcp", c.SRVDomain)
+	if err != nil {
+		return nil, errors.Wrapf(err, "looking up ldap servers of domain: %s", c.SRVDomain)
+	}
+
+	mode := c.GetTLSMode()
+	if mode == config.TLSModeLDAPS {
+		mode = config.TLSModeStartTLS
+	}
+
+	endpoints := make([]Endpoint, 0, len(records))
+	for _, r := range records {
+		endpoints = append(endpoints, Endpoint{
+			Addr:    net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port))),
+			TLSMode: mode,
+		})
+	}
+
+	return endpoints, nil
+}
+
+// endpoints returns the LDAP servers to connect to in order: the configured URLs first, then the servers discovered
+// using DNS SRV records. If the SRV lookup fails, the configured URLs are still used.
+func (c *Client) endpoints(ctx context.Context) ([]Endpoint, error) {
+	endpoints, err := ParseURLs(c.config.LDAP)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.config.LDAP.SRVDomain != "" {
+		discovered, err := lookupSRV(ctx, c.config.LDAP)
+
+		switch {
+		case err != nil && len(endpoints) == 0:
+			return nil, err
+		case err != nil:
+			c.log.Warn().Err(err).Msg("LDAP server discovery failed, using the configured urls")
+		default:
+			endpoints = append(endpoints, discovered...)
+		}
+	}
+
+	if len(endpoints) == 0 {
+		return nil, errors.New("no ldap server configured")
+	}
+
+	return endpoints, nil
+}
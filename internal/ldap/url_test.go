@@ -0,0 +1,127 @@
+package ldap_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/ldap"
+)
+
+func TestParseURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    config.LDAPConfig
+		want    []ldap.Endpoint
+		wantErr bool
+	}{
+		{
+			name: "Test if a bare hostname uses the configured port and tls mode",
+			conf: config.LDAPConfig{URL: "ldap.example.com", Port: 636, UseTLS: true},
+			want: []ldap.Endpoint{{Addr: "ldap.example.com:636", TLSMode: config.TLSModeLDAPS}},
+		},
+		{
+			name: "Test if the scheme determines the tls mode and the default port",
+			conf: config.LDAPConfig{URL: "ldaps://dc1.corp ldap://dc2.corp", Port: 389, UseTLS: false},
+			want: []ldap.Endpoint{
+				{Addr: "dc1.corp:636", TLSMode: config.TLSModeLDAPS},
+				{Addr: "dc2.corp:389", TLSMode: config.TLSModeNone},
+			},
+		},
+		{
+			name: "Test if the port of the url overrides the default port",
+			conf: config.LDAPConfig{URL: "ldaps://dc1.corp:3269", Port: 389},
+			want: []ldap.Endpoint{{Addr: "dc1.corp:3269", TLSMode: config.TLSModeLDAPS}},
+		},
+		{
+			name: "Test if an ldap url is upgraded with starttls",
+			conf: config.LDAPConfig{URL: "ldap://dc1.corp,ldap://dc2.corp", TLSMode: "starttls"},
+			want: []ldap.Endpoint{
+				{Addr: "dc1.corp:389", TLSMode: config.TLSModeStartTLS},
+				{Addr: "dc2.corp:389", TLSMode: config.TLSModeStartTLS},
+			},
+		},
+		{
+			name:    "Test if an unsupported scheme is rejected",
+			conf:    config.LDAPConfig{URL: "ldapi:///var/run/slapd.sock"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := ldap.ParseURLs(&tt.conf)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("ParseURLs() error = %v, wantErr %t", err, tt.wantErr)
+				}
+
+				if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ParseURLs() = %v, want %v", got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	records := []*net.SRV{{Target: "dc1.corp.", Port: 389}}
+
+	tests := []struct {
+		name    string
+		conf    config.LDAPConfig
+		srvErr  error
+		want    []ldap.Endpoint
+		wantErr bool
+	}{
+		{
+			name: "Test if the discovered servers use starttls instead of ldaps",
+			conf: config.LDAPConfig{SRVDomain: "corp", TLSMode: "ldaps"},
+			want: []ldap.Endpoint{{Addr: "dc1.corp:389", TLSMode: config.TLSModeStartTLS}},
+		},
+		{
+			name: "Test if the discovered servers follow the configured urls",
+			conf: config.LDAPConfig{URL: "ldaps://ldap.corp", SRVDomain: "corp", TLSMode: "none"},
+			want: []ldap.Endpoint{
+				{Addr: "ldap.corp:636", TLSMode: config.TLSModeLDAPS},
+				{Addr: "dc1.corp:389", TLSMode: config.TLSModeNone},
+			},
+		},
+		{
+			name:   "Test if the configured urls are used if the discovery fails",
+			conf:   config.LDAPConfig{URL: "ldaps://ldap.corp", SRVDomain: "corp"},
+			srvErr: errors.New("no such host"),
+			want:   []ldap.Endpoint{{Addr: "ldap.corp:636", TLSMode: config.TLSModeLDAPS}},
+		},
+		{
+			name:    "Test if a failed discovery without urls is an error",
+			conf:    config.LDAPConfig{SRVDomain: "corp"},
+			srvErr:  errors.New("no such host"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer ldap.SetResolveSRV(func(_ context.Context, _, _, _ string) (string, []*net.SRV, error) {
+				if tt.srvErr != nil {
+					return "", nil, tt.srvErr
+				}
+
+				return "", records, nil
+			})()
+
+			got, err := ldap.Endpoints(context.Background(), &config.Config{LDAP: &tt.conf})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Endpoints() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Endpoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
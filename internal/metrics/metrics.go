@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoglobals
+var (
+	// Retries counts the retried requests per subsystem.
+	Retries = expvar.NewMap("retries")
+
+	// ConfigReloads counts the successful and the rejected config reloads.
+	ConfigReloads = expvar.NewMap("config_reloads")
+
+	// FailedRuns counts the failed sync runs per profile.
+	FailedRuns = expvar.NewMap("failed_runs")
+
+	// Reports holds the report of the last sync run per profile.
+	Reports = expvar.NewMap("reports")
+)
+
+// Serve exposes the metrics in expvar format on /debug/vars until ctx is done.
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second, //nolint:mnd
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		_ = srv.Close()
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrapf(err, "serving metrics on: %s", addr)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+// Package naming renders the names and the descriptions of the Gitea organizations and teams from the LDAP entries
+// using text/template expressions, eg.: {{ .Attr "department" | lower }}.
+package naming
+
+import (
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+)
+
+// Funcs are the functions available in the templates besides the text/template builtins.
+//
+//nolint:gochecknoglobals
+var Funcs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, replacement, s string) string { return strings.ReplaceAll(s, old, replacement) },
+	// regexReplace replaces the matches of the expression, the replacement can reference the capture groups ($1).
+	"regexReplace": func(expr, replacement, s string) (string, error) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return "", err
+		}
+
+		return re.ReplaceAllString(s, replacement), nil
+	},
+}
+
+// Parse parses the template. An empty text returns nil.
+func Parse(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	t, err := template.New(name).Funcs(Funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing template: %s", name)
+	}
+
+	return t, nil
+}
+
+// Data is the data of the templates.
+type Data struct {
+	entry *ldap.Entry
+	// Parent is the name of the organization of a team, empty for organizations.
+	Parent string
+}
+
+func NewData(entry *ldap.Entry, parent string) *Data {
+	return &Data{entry: entry, Parent: parent}
+}
+
+// Attr returns the first value of the attribute.
+func (d *Data) Attr(name string) string {
+	return d.entry.GetAttributeValue(name)
+}
+
+// Attrs returns the values of the attribute.
+func (d *Data) Attrs(name string) []string {
+	return d.entry.GetAttributeValues(name)
+}
+
+// DN returns the DN of the entry.
+func (d *Data) DN() string {
+	return d.entry.DN
+}
+
+// RDN returns the value of the first component of the DN with the given type, eg.: {{ .RDN "ou" }} returns
+// "engineering" for cn=backend,ou=engineering,dc=example,dc=com.
+func (d *Data) RDN(attr string) string {
+	dn, err := ldap.ParseDN(d.entry.DN)
+	if err != nil {
+		return ""
+	}
+
+	for _, rdn := range dn.RDNs {
+		for _, a := range rdn.Attributes {
+			if strings.EqualFold(a.Type, attr) {
+				return a.Value
+			}
+		}
+	}
+
+	return ""
+}
+
+// Render executes the template. The result is trimmed.
+func Render(t *template.Template, data *Data) (string, error) {
+	var b strings.Builder
+
+	if err := t.Execute(&b, data); err != nil {
+		return "", errors.Wrapf(err, "rendering template: %s", t.Name())
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
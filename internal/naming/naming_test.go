@@ -0,0 +1,164 @@
+package naming_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/naming"
+)
+
+func TestRender(t *testing.T) {
+	entry := ldap.NewEntry("cn=GIT_Backend,ou=Engineering,dc=example,dc=com", map[string][]string{
+		"cn":          {"GIT_Backend"},
+		"description": {"Backend developers"},
+	})
+
+	tests := []struct {
+		name   string
+		text   string
+		parent string
+		want   string
+	}{
+		{
+			name: "Test if an attribute is rendered",
+			text: `{{ .Attr "description" }}`,
+			want: "Backend developers",
+		},
+		{
+			name: "Test if a prefix is stripped and the name is lowercased",
+			text: `{{ .Attr "cn" | trimPrefix "GIT_" | lower }}`,
+			want: "backend",
+		},
+		{
+			name: "Test if a regular expression replaces the name",
+			text: `{{ .Attr "cn" | regexReplace "^GIT_(.*)$" "team-$1" }}`,
+			want: "team-Backend",
+		},
+		{
+			name: "Test if a DN component is rendered",
+			text: `{{ .RDN "ou" | lower }}-{{ .Attr "cn" | trimPrefix "GIT_" }}`,
+			want: "engineering-Backend",
+		},
+		{
+			name:   "Test if the parent name is rendered",
+			text:   `{{ .Parent }}/{{ .Attr "cn" }}`,
+			parent: "developers",
+			want:   "developers/GIT_Backend",
+		},
+		{
+			name: "Test if a missing attribute is rendered empty",
+			text: ` {{ .Attr "department" }} `,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := naming.Parse("test", tt.text)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			got, err := naming.Render(tpl, naming.NewData(entry, tt.parent))
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserName(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Test if a valid name is kept",
+			in:   "john.doe",
+			want: "john.doe",
+		},
+		{
+			name: "Test if the umlauts and the diacritics are transliterated",
+			in:   "Jürgen Müller-Ångström",
+			want: "Juergen-Mueller-Angstroem",
+		},
+		{
+			name: "Test if the special characters are collapsed and trimmed",
+			in:   "_GIT__Dev Ops.",
+			want: "GIT_Dev-Ops",
+		},
+		{
+			name: "Test if a long name is truncated",
+			in:   "a-very-long-organization-name-which-is-longer-than-allowed",
+			want: "a-very-long-organization-name-which-is-l",
+		},
+		{
+			name:    "Test if a reserved name is rejected",
+			in:      "Admin",
+			wantErr: naming.ErrReservedName,
+		},
+		{
+			name:    "Test if a reserved pattern is rejected",
+			in:      "john.keys",
+			wantErr: naming.ErrReservedName,
+		},
+		{
+			name:    "Test if a name without valid characters is rejected",
+			in:      "世界",
+			wantErr: naming.ErrInvalidName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := naming.UserName(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UserName() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("UserName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTeamName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "Test if a valid name is kept",
+			in:   "backend_devs",
+			want: "backend_devs",
+		},
+		{
+			name: "Test if the invalid characters are replaced",
+			in:   "Développeurs Back-End (Zürich)",
+			want: "Developpeurs-Back-End-Zuerich",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := naming.TeamName(tt.in)
+			if err != nil {
+				t.Fatalf("TeamName() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("TeamName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package naming
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/unicode/norm"
+)
+
+// The maximum length of the names accepted by Gitea.
+const (
+	MaxUserNameLength = 40
+	MaxTeamNameLength = 30
+)
+
+var (
+	// ErrInvalidName is returned if nothing remains of a name after the sanitization.
+	ErrInvalidName = errors.New("invalid name")
+	// ErrReservedName is returned if the name is reserved by Gitea.
+	ErrReservedName = errors.New("reserved name")
+)
+
+//nolint:gochecknoglobals
+var (
+	// transliterations are the letters which are not transliterated by removing their diacritics.
+	transliterations = strings.NewReplacer(
+		"ä", "ae", "ö", "oe", "ü", "ue", "Ä", "Ae", "Ö", "Oe", "Ü", "Ue", "ß", "ss",
+		"æ", "ae", "Æ", "Ae", "œ", "oe", "Œ", "Oe", "ø", "o", "Ø", "O", "đ", "d", "Đ", "D",
+		"ł", "l", "Ł", "L", "ð", "d", "Ð", "D", "þ", "th", "Þ", "Th", "ı", "i",
+	)
+	invalidChars = regexp.MustCompile(`[^\w.-]+`)
+	// specialChars are runs of the characters which can't start or end a user name and can't follow each other.
+	specialChars = regexp.MustCompile(`[-_.]{2,}`)
+	teamName     = regexp.MustCompile(`^[\w.-]+$`)
+	// reservedNames are the user and organization names reserved by Gitea.
+	reservedNames = []string{
+		".", "..", ".well-known", "admin", "api", "assets", "attachments", "avatar", "avatars", "captcha", "commits",
+		"debug", "error", "explore", "favicon.ico", "ghost", "issues", "login", "manifest.json", "metrics",
+		"milestones", "new", "notifications", "org", "pulls", "raw", "repo", "repo-avatars", "robots.txt", "search",
+		"serviceworker.js", "ssh_info", "swagger.v1.json", "user", "v2", "gitea-actions",
+	}
+	reservedPatterns = []string{"*.keys", "*.gpg", "*.rss", "*.atom", "*.png"}
+)
+
+// transliterate replaces the letters with their ASCII equivalents, eg.: "Müller" becomes "Mueller" and "José" becomes
+// "Jose".
+func transliterate(s string) string {
+	s = norm.NFD.String(transliterations.Replace(norm.NFC.String(s)))
+
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+
+		return r
+	}, s)
+}
+
+// UserName returns the name normalized to the rules of the Gitea user and organization names: the letters are
+// transliterated, the invalid characters are replaced by dashes, the name starts and ends with a letter or a digit,
+// no two of the characters "-", "_" and "." follow each other and it's at most MaxUserNameLength long.
+func UserName(name string) (string, error) {
+	s := invalidChars.ReplaceAllString(transliterate(name), "-")
+	s = specialChars.ReplaceAllStringFunc(s, func(m string) string { return m[:1] })
+	s = strings.Trim(s, "-_.")
+
+	if len(s) > MaxUserNameLength {
+		s = strings.TrimRight(s[:MaxUserNameLength], "-_.")
+	}
+
+	if s == "" {
+		return "", errors.Wrapf(ErrInvalidName, "%q", name)
+	}
+
+	lower := strings.ToLower(s)
+	for _, r := range reservedNames {
+		if lower == r {
+			return "", errors.Wrapf(ErrReservedName, "%q", s)
+		}
+	}
+
+	for _, p := range reservedPatterns {
+		if ok, _ := path.Match(p, lower); ok {
+			return "", errors.Wrapf(ErrReservedName, "%q", s)
+		}
+	}
+
+	return s, nil
+}
+
+// TeamName returns the name normalized to the rules of the Gitea team names: the letters are transliterated, the
+// invalid characters are replaced by dashes and it's at most MaxTeamNameLength long.
+func TeamName(name string) (string, error) {
+	s := strings.Trim(invalidChars.ReplaceAllString(transliterate(name), "-"), "-")
+
+	if len(s) > MaxTeamNameLength {
+		s = s[:MaxTeamNameLength]
+	}
+
+	if s == "" {
+		return "", errors.Wrapf(ErrInvalidName, "%q", name)
+	}
+
+	return s, nil
+}
+
+// IsValidTeamName reports whether Gitea accepts the team name.
+func IsValidTeamName(name string) bool {
+	return teamName.MatchString(name) && len(name) <= MaxTeamNameLength
+}
@@ -0,0 +1,113 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/metrics"
+)
+
+// Policy describes how many times and how often a failed operation is retried.
+type Policy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func NewPolicy(conf *config.RetryConfig) Policy {
+	return Policy{
+		MaxAttempts:    conf.MaxAttempts,
+		InitialBackoff: conf.InitialBackoff,
+		MaxBackoff:     conf.MaxBackoff,
+	}
+}
+
+// Error marks an error as transient. If After is set, it overrides the computed backoff (eg.: Retry-After header).
+type Error struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable wraps err to signal Do that the operation can be retried.
+func Retryable(err error, after time.Duration) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Err: err, After: after}
+}
+
+// Do calls fn until it succeeds, returns a non-retryable error, the attempts are exhausted or ctx is done.
+// Name identifies the subsystem (eg.: gitea, ldap) in the logs and in the metrics.
+func Do(ctx context.Context, p Policy, name string, log zerolog.Logger, fn func() error) error {
+	attempts := max(p.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		var re *Error
+		if !errors.As(err, &re) {
+			return err
+		}
+
+		if attempt >= attempts || ctx.Err() != nil {
+			return re.Err
+		}
+
+		delay := re.After
+		if delay <= 0 {
+			delay = p.Backoff(attempt)
+		}
+
+		log.Warn().Err(re.Err).Msgf("%s request failed, retrying in %s (attempt %d/%d)", name, delay, attempt, attempts)
+		metrics.Retries.Add(name, 1)
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return errors.Wrap(ctx.Err(), re.Err.Error())
+		case <-timer.C:
+		}
+	}
+}
+
+// Backoff returns the exponential backoff of the given attempt with jitter. The result is between the half and
+// the full value of the exponential delay, capped by MaxBackoff.
+func (p Policy) Backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2 //nolint:mnd
+
+	return half + rand.N(delay-half+1) //nolint:gosec // jitter does not need a secure random source.
+}
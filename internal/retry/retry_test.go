@@ -0,0 +1,114 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/retry"
+)
+
+var errTransient = errors.New("transient")
+
+func TestDo(t *testing.T) {
+	policy := retry.Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		failures  int
+		retryable bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "Test if a successful call is not retried",
+			failures:  0,
+			retryable: true,
+			wantCalls: 1,
+			wantErr:   false,
+		},
+		{
+			name:      "Test if a transient failure is retried",
+			failures:  2,
+			retryable: true,
+			wantCalls: 3,
+			wantErr:   false,
+		},
+		{
+			name:      "Test if the attempts are limited",
+			failures:  5,
+			retryable: true,
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name:      "Test if a permanent failure is not retried",
+			failures:  5,
+			retryable: false,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				calls := 0
+
+				err := retry.Do(context.Background(), policy, "test", zerolog.Nop(), func() error {
+					calls++
+
+					if calls > tt.failures {
+						return nil
+					}
+
+					if tt.retryable {
+						return retry.Retryable(errTransient, 0)
+					}
+
+					return errTransient
+				})
+
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Do() error = %v, wantErr %t", err, tt.wantErr)
+				}
+
+				if err != nil && !errors.Is(err, errTransient) {
+					t.Errorf("Do() error = %v, want %v", err, errTransient)
+				}
+
+				if calls != tt.wantCalls {
+					t.Errorf("Do() calls = %d, want %d", calls, tt.wantCalls)
+				}
+			},
+		)
+	}
+}
+
+func TestPolicyBackoff(t *testing.T) {
+	policy := retry.Policy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	tests := []struct {
+		name    string
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{name: "Test the first attempt", attempt: 1, min: 500 * time.Millisecond, max: time.Second},
+		{name: "Test the third attempt", attempt: 3, min: 2 * time.Second, max: 4 * time.Second},
+		{name: "Test if the backoff is capped", attempt: 10, min: 2500 * time.Millisecond, max: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := policy.Backoff(tt.attempt); got < tt.min || got > tt.max {
+					t.Errorf("Backoff() = %s, want between %s and %s", got, tt.min, tt.max)
+				}
+			},
+		)
+	}
+}
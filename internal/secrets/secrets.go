@@ -0,0 +1,153 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+)
+
+// Provider resolves secret references of a single scheme, eg.: vault:<path>#<key>.
+type Provider interface {
+	Scheme() string
+	Get(ctx context.Context, ref string) (string, error)
+}
+
+// Resolver resolves secret references using the registered providers.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver returns a resolver with the built-in providers (file and, if configured, vault).
+func NewResolver(conf *config.Config) (*Resolver, error) {
+	r := &Resolver{providers: make(map[string]Provider)}
+	r.Register(&FileProvider{})
+
+	if conf.Vault != nil && conf.Vault.Address != "" {
+		v, err := NewVaultProvider(conf.Vault)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Register(v)
+	}
+
+	return r, nil
+}
+
+func (r *Resolver) Register(p Provider) {
+	r.providers[p.Scheme()] = p
+}
+
+// Get returns the value of the secret. Values without a known scheme prefix are returned as they are.
+func (r *Resolver) Get(ctx context.Context, value string) (string, error) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return value, nil
+	}
+
+	p, ok := r.providers[scheme]
+	if !ok {
+		return value, nil
+	}
+
+	secret, err := p.Get(ctx, ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "resolving %s secret", scheme)
+	}
+
+	return secret, nil
+}
+
+// Resolve returns a copy of conf with every secret loaded from its file or secret provider. It's called before every
+// run, so rotated credentials are picked up without a restart.
+func Resolve(ctx context.Context, conf *config.Config) (*config.Config, error) {
+	r, err := NewResolver(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := *conf
+	ldapConf := *conf.LDAP
+	resolved.LDAP = &ldapConf
+
+	if ldapConf.BindPasswordFile != "" {
+		ldapConf.BindPassword = "file:" + ldapConf.BindPasswordFile
+	}
+
+	if ldapConf.BindPassword, err = r.Get(ctx, ldapConf.BindPassword); err != nil {
+		return nil, err
+	}
+
+	if resolved.Gitea, err = resolveGitea(ctx, r, conf.Gitea); err != nil {
+		return nil, err
+	}
+
+	resolved.Targets = make([]*config.Target, 0, len(conf.Targets))
+
+	for _, t := range conf.Targets {
+		target := *t
+
+		if target.Gitea, err = resolveGitea(ctx, r, t.Gitea); err != nil {
+			return nil, errors.Wrapf(err, "target: %s", t.Name)
+		}
+
+		resolved.Targets = append(resolved.Targets, &target)
+	}
+
+	return &resolved, nil
+}
+
+func resolveGitea(ctx context.Context, r *Resolver, conf *config.GiteaConfig) (*config.GiteaConfig, error) {
+	var err error
+
+	giteaConf := *conf
+
+	secrets := []struct {
+		value *string
+		file  string
+	}{
+		{value: &giteaConf.Token, file: giteaConf.TokenFile},
+		{value: &giteaConf.Password, file: giteaConf.PasswordFile},
+	}
+
+	for _, s := range secrets {
+		if s.file != "" {
+			*s.value = "file:" + s.file
+		}
+
+		if *s.value, err = r.Get(ctx, *s.value); err != nil {
+			return nil, err
+		}
+	}
+
+	// Custom gitea headers may contain credentials as well (eg.: Cloudflare Access tokens).
+	giteaConf.Headers = make(map[string]string, len(conf.Headers))
+
+	for k, v := range conf.Headers {
+		if giteaConf.Headers[k], err = r.Get(ctx, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return &giteaConf, nil
+}
+
+// FileProvider reads secrets from files, eg.: Kubernetes or Docker secrets. The surrounding whitespace is trimmed.
+type FileProvider struct{}
+
+func (p *FileProvider) Scheme() string {
+	return "file"
+}
+
+func (p *FileProvider) Get(_ context.Context, ref string) (string, error) {
+	b, err := os.ReadFile(ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading secret file: %s", ref)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
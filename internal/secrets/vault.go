@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+)
+
+const kvVersion2 = 2
+
+// VaultProvider reads secrets from a HashiCorp Vault KV secrets engine (version 1 or 2).
+// References are in <path>#<key> format, eg.: vault:gitea-ldap-sync#token.
+type VaultProvider struct {
+	conf   *config.VaultConfig
+	token  string
+	client *http.Client
+}
+
+func NewVaultProvider(conf *config.VaultConfig) (*VaultProvider, error) {
+	token := conf.Token
+
+	if conf.TokenFile != "" {
+		t, err := (&FileProvider{}).Get(context.Background(), conf.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+
+		token = t
+	}
+
+	return &VaultProvider{
+		conf:   conf,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second}, //nolint:mnd
+	}, nil
+}
+
+func (p *VaultProvider) Scheme() string {
+	return "vault"
+}
+
+func (p *VaultProvider) Get(ctx context.Context, ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || key == "" {
+		return "", errors.Errorf("invalid vault secret reference, expected <path>#<key>: %s", ref)
+	}
+
+	mount := p.conf.Mount
+	if p.conf.KVVersion == kvVersion2 {
+		mount += "/data"
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s", strings.TrimSuffix(p.conf.Address, "/"), mount, path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "creating vault request: %s", path)
+	}
+
+	req.Header.Set("X-Vault-Token", p.token)
+
+	if p.conf.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.conf.Namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading vault secret: %s", path)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("reading vault secret: %s (status: %s)", path, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errors.Wrapf(err, "decoding vault secret: %s", path)
+	}
+
+	data := body.Data
+
+	// KV version 2 wraps the secret into an additional data field.
+	if p.conf.KVVersion == kvVersion2 {
+		data = nil
+
+		if err := json.Unmarshal(body.Data["data"], &data); err != nil {
+			return "", errors.Wrapf(err, "decoding vault secret: %s", path)
+		}
+	}
+
+	var value string
+
+	raw, ok := data[key]
+	if !ok {
+		return "", errors.Errorf("key not found in vault secret: %s#%s", path, key)
+	}
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", errors.Wrapf(err, "vault secret is not a string: %s#%s", path, key)
+	}
+
+	return value, nil
+}
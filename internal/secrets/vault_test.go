@@ -0,0 +1,84 @@
+package secrets_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/secrets"
+)
+
+func TestVaultProviderGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/gitea-ldap-sync":
+			_, _ = w.Write([]byte(`{"data":{"data":{"token":"kv2-token"},"metadata":{"version":1}}}`))
+		case "/v1/kv/gitea-ldap-sync":
+			_, _ = w.Write([]byte(`{"data":{"token":"kv1-token"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		conf    config.VaultConfig
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Test if a secret is read from kv version 2",
+			conf: config.VaultConfig{Address: srv.URL, Token: "root", Mount: "secret", KVVersion: 2},
+			ref:  "gitea-ldap-sync#token",
+			want: "kv2-token",
+		},
+		{
+			name: "Test if a secret is read from kv version 1",
+			conf: config.VaultConfig{Address: srv.URL, Token: "root", Mount: "kv", KVVersion: 1},
+			ref:  "gitea-ldap-sync#token",
+			want: "kv1-token",
+		},
+		{
+			name:    "Test if a missing key is an error",
+			conf:    config.VaultConfig{Address: srv.URL, Token: "root", Mount: "secret", KVVersion: 2},
+			ref:     "gitea-ldap-sync#password",
+			wantErr: true,
+		},
+		{
+			name:    "Test if a denied request is an error",
+			conf:    config.VaultConfig{Address: srv.URL, Token: "invalid", Mount: "secret", KVVersion: 2},
+			ref:     "gitea-ldap-sync#token",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				p, err := secrets.NewVaultProvider(&tt.conf)
+				if err != nil {
+					t.Fatalf("NewVaultProvider() error = %v", err)
+				}
+
+				got, err := p.Get(context.Background(), tt.ref)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Get() error = %v, wantErr %t", err, tt.wantErr)
+				}
+
+				if got != tt.want {
+					t.Errorf("Get() = %s, want %s", got, tt.want)
+				}
+			},
+		)
+	}
+}
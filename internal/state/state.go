@@ -0,0 +1,121 @@
+// Package state persists the data the sync has to remember between the runs (eg.: the objects it created) in a JSON
+// file. If no file is configured, the state is only kept in memory until the process exits.
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Store is a key-value store of JSON documents. It's safe for concurrent use.
+type Store struct {
+	mu   sync.Mutex
+	path string
+	data map[string]json.RawMessage
+}
+
+//nolint:gochecknoglobals
+var (
+	storesMu sync.Mutex
+	stores   = make(map[string]*Store)
+)
+
+// Open returns the store of the file. The file is read once, the same store is returned for the same path, so the
+// profiles running concurrently share it. An empty path returns the in-memory store.
+func Open(path string) (*Store, error) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+
+	if s, ok := stores[path]; ok {
+		return s, nil
+	}
+
+	s := &Store{path: path, data: make(map[string]json.RawMessage)}
+
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "reading state file: %s", path)
+		}
+
+		if len(b) != 0 {
+			if err := json.Unmarshal(b, &s.data); err != nil {
+				return nil, errors.Wrapf(err, "parsing state file: %s", path)
+			}
+		}
+	}
+
+	stores[path] = s
+
+	return s, nil
+}
+
+// Get decodes the value of the key into v. It reports whether the key exists.
+func (s *Store) Get(key string, v interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, ok := s.data[key]
+	if !ok {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return false, errors.Wrapf(err, "decoding state: %s", key)
+	}
+
+	return true, nil
+}
+
+// Set stores the value of the key and writes the state file.
+func (s *Store) Set(key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrapf(err, "encoding state: %s", key)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[key] = raw
+
+	return s.save()
+}
+
+// save writes the state file atomically, so a crash never leaves a partially written file behind.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding state")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "writing state file: %s", s.path)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+
+		return errors.Wrapf(err, "writing state file: %s", s.path)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "writing state file: %s", s.path)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return errors.Wrapf(err, "writing state file: %s", s.path)
+	}
+
+	return nil
+}
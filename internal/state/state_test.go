@@ -0,0 +1,75 @@
+package state_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/state"
+)
+
+func TestStore(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{
+			name: "Test if the state is written to the file",
+			path: filepath.Join(t.TempDir(), "state.json"),
+		},
+		{
+			name: "Test if the state is kept in memory without a file",
+			path: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := state.Open(tt.path)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+
+			want := map[string][]string{"org/repo": {"alice", "bob"}}
+
+			if err := s.Set("collaborators", want); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			again, err := state.Open(tt.path)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+
+			var got map[string][]string
+
+			if ok, err := again.Get("collaborators", &got); err != nil || !ok {
+				t.Fatalf("Get() = %v, %v", ok, err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Get() = %v, want %v", got, want)
+			}
+
+			if tt.path == "" {
+				return
+			}
+
+			b, err := os.ReadFile(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var file map[string]map[string][]string
+			if err := json.Unmarshal(b, &file); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(file["collaborators"], want) {
+				t.Errorf("state file = %v, want %v", file["collaborators"], want)
+			}
+		})
+	}
+}
@@ -1,47 +1,104 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/janosmiko/gitea-ldap-sync/internal/app"
 	"github.com/janosmiko/gitea-ldap-sync/internal/config"
 	"github.com/janosmiko/gitea-ldap-sync/internal/logger"
+	"github.com/janosmiko/gitea-ldap-sync/internal/metrics"
+	"github.com/janosmiko/gitea-ldap-sync/internal/secrets"
 )
 
+// conf is the active configuration. It is swapped when the configuration is reloaded, every sync run uses the
+// configuration which was active when it started.
+//
 //nolint:gochecknoglobals
-var conf *config.Config
+var conf atomic.Pointer[config.Config]
+
+// ownership prevents the profiles from deleting the objects of each other.
+//
+//nolint:gochecknoglobals
+var ownership = app.NewOwnership()
 
 func main() {
+	if code, ok := runCommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
 	logger.Configure()
 
 	log := log.Logger.With().Str("tag", "[main]").Logger()
 
-	var err error
-
-	conf, err = config.New()
+	cfg, err := config.New()
 	if err != nil {
 		log.Fatal().Err(err).Msg("Error")
 	}
 
-	mainJob() // First run for check settings
+	conf.Store(cfg)
+	ownership.SetProfiles(profileNames(cfg)...)
+
+	ctx := signalContext()
+
+	reloaded := make(chan *config.Config, 1)
+	watchConfig(ctx, config.FileUsed(), reloaded)
+
+	if cfg.MetricsListenAddress != "" {
+		go func() {
+			if err := metrics.Serve(ctx, cfg.MetricsListenAddress); err != nil {
+				log.Error().Err(err).Msg("Metrics server stopped")
+			}
+		}()
+	}
 
-	if !conf.CronEnabled {
+	// First run for check settings
+	failed := false
+
+	for _, p := range cfg.GetProfiles() {
+		if !mainJob(ctx, p.Name) {
+			failed = true
+		}
+	}
+
+	if !cfg.CronEnabled {
 		log.Info().Msg("Cron is disabled, shutting down...")
 
+		if failed {
+			os.Exit(1)
+		}
+
 		return
 	}
 
-	runCron()
+	runCron(ctx, reloaded)
 }
 
-func runCron() {
-	log := log.Logger.With().Str("tag", "[cron]").Logger()
+func profileNames(cfg *config.Config) []string {
+	profiles := cfg.GetProfiles()
+
+	names := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+
+	return names
+}
+
+// signalContext returns a context which is canceled when the process receives a termination signal.
+// Canceling the context aborts the running sync.
+func signalContext() context.Context {
+	log := log.Logger.With().Str("tag", "[main]").Logger()
+
+	ctx, cancel := context.WithCancel(context.Background())
 
 	sig := make(chan os.Signal, 1)
 	signal.Notify(
@@ -51,40 +108,164 @@ func runCron() {
 		syscall.SIGQUIT,
 	)
 
-	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.VerbosePrintfLogger(logger.CronLogger()))))
-	_, _ = c.AddFunc(conf.CronTimer, mainJob)
+	go func() {
+		s := <-sig
+		log.Info().Msgf("Received signal: %v", s)
+
+		cancel()
+	}()
+
+	return ctx
+}
+
+// runCron runs the sync of every profile on its schedule until ctx is done. The jobs are rescheduled if a config
+// reload changes the profiles or their cron timers.
+func runCron(ctx context.Context, reloaded <-chan *config.Config) {
+	log := log.Logger.With().Str("tag", "[cron]").Logger()
+
+	c := cron.New()
+	jobs := make(map[string]*scheduledJob)
+
+	schedule(ctx, c, jobs, conf.Load())
 
 	c.Start()
 
-	<-sig
-	log.Info().Msgf("Received signal: %v", sig)
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case cfg := <-reloaded:
+			schedule(ctx, c, jobs, cfg)
+		}
+	}
 
-	ctx := c.Stop()
+	stopCtx := c.Stop()
 
 	// Wait for running jobs to complete
 	const timeout = 60
 
 	select {
-	case <-ctx.Done():
+	case <-stopCtx.Done():
 		log.Info().Msg("All jobs completed, shutting down...")
 	case <-time.After(timeout * time.Second):
 		log.Info().Msg("Shutdown timed out after 60 seconds")
 	}
 }
 
-func mainJob() {
+type scheduledJob struct {
+	id    cron.EntryID
+	timer string
+	job   cron.Job
+}
+
+// schedule updates the cron entries of the profiles. The jobs are kept across the updates, so the sync of a profile
+// never overlaps with itself.
+func schedule(ctx context.Context, c *cron.Cron, jobs map[string]*scheduledJob, cfg *config.Config) {
+	log := log.Logger.With().Str("tag", "[cron]").Logger()
+
+	active := make(map[string]struct{})
+
+	for _, p := range cfg.GetProfiles() {
+		active[p.Name] = struct{}{}
+
+		j, ok := jobs[p.Name]
+		if !ok {
+			name := p.Name
+			j = &scheduledJob{
+				job: cron.NewChain(cron.SkipIfStillRunning(cron.VerbosePrintfLogger(logger.CronLogger()))).
+					Then(cron.FuncJob(func() { mainJob(ctx, name) })),
+			}
+			jobs[name] = j
+		}
+
+		if j.timer == p.CronTimer {
+			continue
+		}
+
+		id, err := c.AddJob(p.CronTimer, j.job)
+		if err != nil {
+			log.Error().Err(err).Msgf("Invalid cron timer: %s (profile: %s)", p.CronTimer, p.Name)
+
+			continue
+		}
+
+		c.Remove(j.id)
+		j.id, j.timer = id, p.CronTimer
+
+		log.Info().Msgf("Scheduled the sync: %s (profile: %s)", j.timer, p.Name)
+	}
+
+	for name, j := range jobs {
+		if _, ok := active[name]; !ok {
+			c.Remove(j.id)
+			delete(jobs, name)
+
+			log.Info().Msgf("Unscheduled the sync of the removed profile: %s", name)
+		}
+	}
+}
+
+// mainJob runs the sync of a profile. A failed run is logged and counted, the sync is retried on the next run. It
+// reports whether the run succeeded.
+func mainJob(ctx context.Context, profile string) bool {
 	log := log.Logger.With().Str("tag", "[mainjob]").Logger()
-	log.Info().Msg("Job started")
 
-	c, err := app.New(conf)
+	if ctx.Err() != nil {
+		return false
+	}
+
+	cfg := conf.Load()
+
+	p := cfg.GetProfile(profile)
+	if p == nil {
+		log.Info().Msgf("Profile does not exist anymore, skipping: %s", profile)
+
+		return true
+	}
+
+	log.Info().Msgf("Job started (profile: %s)", profile)
+
+	// Secrets are resolved on every run, so rotated credentials are picked up without a restart.
+	runConf, err := secrets.Resolve(ctx, cfg.ForProfile(p))
+	if err != nil {
+		return failRun(log, profile, err)
+	}
+
+	c, err := app.New(ctx, runConf, ownership)
 	if err != nil {
-		log.Fatal().Msgf("Error: %s", err)
+		if ctx.Err() != nil {
+			log.Info().Msgf("Job aborted: %s", err)
+
+			return false
+		}
+
+		return failRun(log, profile, err)
 	}
 	defer c.Close()
 
-	if err := c.Run(); err != nil {
-		log.Panic().Msgf("Error: %s", err)
+	reports, err := c.Run(ctx)
+	for _, r := range reports {
+		metrics.Reports.Set(r.Profile+"/"+r.Target, r)
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Info().Msgf("Job aborted: %s", err)
+
+			return false
+		}
+
+		return failRun(log, profile, err)
 	}
 
-	log.Info().Msg("Job done")
+	log.Info().Msgf("Job done (profile: %s)", profile)
+
+	return true
+}
+
+// failRun logs and counts the failed run of a profile.
+func failRun(log zerolog.Logger, profile string, err error) bool {
+	metrics.FailedRuns.Add(profile, 1)
+	log.Error().Err(err).Msgf("Job failed (profile: %s)", profile)
+
+	return false
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/janosmiko/gitea-ldap-sync/internal/config"
+	"github.com/janosmiko/gitea-ldap-sync/internal/metrics"
+)
+
+//nolint:gochecknoglobals
+var reloadMu sync.Mutex
+
+// watchConfig reloads the configuration when the config file changes or the process receives SIGHUP. The new
+// configuration is sent to reloaded after it has been swapped, replacing the configuration which was not received yet.
+func watchConfig(ctx context.Context, path string, reloaded chan *config.Config) {
+	log := log.Logger.With().Str("tag", "[config]").Logger()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sig)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case s := <-sig:
+				log.Info().Msgf("Received signal: %v", s)
+
+				reloadConfig(path, reloaded)
+			}
+		}
+	}()
+
+	if path == "" {
+		return
+	}
+
+	go func() {
+		if err := config.Watch(ctx, path, func() {
+			log.Info().Msgf("Config file changed: %s", path)
+
+			reloadConfig(path, reloaded)
+		}); err != nil {
+			log.Error().Err(err).Msg("Config file is not watched, use SIGHUP to reload it")
+		}
+	}()
+}
+
+// reloadConfig loads and validates the configuration and swaps it for the next sync runs. An invalid configuration
+// is rejected and the current one is kept.
+func reloadConfig(path string, reloaded chan *config.Config) {
+	log := log.Logger.With().Str("tag", "[config]").Logger()
+
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	c, err := config.Load(path)
+	if err != nil {
+		metrics.ConfigReloads.Add("rejected", 1)
+		log.Error().Err(err).Msg("Config reload rejected, keeping the current configuration")
+
+		return
+	}
+
+	old := conf.Swap(c)
+	ownership.SetProfiles(profileNames(c)...)
+
+	metrics.ConfigReloads.Add("success", 1)
+	log.Info().Msg("Configuration reloaded")
+
+	if old.CronEnabled != c.CronEnabled || old.MetricsListenAddress != c.MetricsListenAddress {
+		log.Warn().Msg("Changing cron_enabled or metrics_listen_address requires a restart")
+	}
+
+	// The pending configuration is outdated, it's replaced, so the latest one is never dropped. Only one reload runs
+	// at a time, so the send does not block after the drain.
+	select {
+	case <-reloaded:
+	default:
+	}
+
+	reloaded <- c
+}